@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+/* ---------- config file ---------- */
+
+// Prefix of the environment variables that set flags, e.g. PDF_EXTRACT_WORKERS=4
+// for --workers. Repeatable flags take several values separated by ";".
+const envPrefix = "PDF_EXTRACT_"
+
+// configFile holds the settings of a --config file: flag names (with - or
+// _) and their values, a list for repeatable flags such as input.
+type configFile struct {
+	path   string
+	values map[string][]string
+	// license is license_key, which is only read when no other license
+	// source is set; see resolveLicenseKey.
+	license string
+}
+
+var (
+	// The loaded config file, nil without one.
+	runConfig *configFile
+	// Where each flag's value came from, for config print.
+	settingSources = make(map[string]string)
+	// The subcommands each subcommand flag belongs to, e.g. "dedupe, diff"
+	// for hash-distance; see findSubcommandSettings.
+	subcommandOf map[string]string
+)
+
+// The subcommands with flags of their own and the functions registering them.
+var subcommandFlags = []struct {
+	name     string
+	register func()
+}{
+	{"serve", registerServeFlags},
+	{"dedupe", registerDedupeFlags},
+	{"diff", registerDiffFlags},
+}
+
+// findSubcommandSettings registers every subcommand's flags on a scratch
+// flag set to learn their names, so settings of other subcommands in a
+// shared config file are not reported as unknown. It has to run before the
+// flags are registered for real, which rebinds the flag variables.
+func findSubcommandSettings() map[string]string {
+	saved := flag.CommandLine
+	defer func() { flag.CommandLine = saved }()
+
+	owners := make(map[string]string)
+	for _, sub := range subcommandFlags {
+		flag.CommandLine = flag.NewFlagSet(sub.name, flag.ContinueOnError)
+		sub.register()
+		flag.CommandLine.VisitAll(func(f *flag.Flag) {
+			if owners[f.Name] != "" {
+				owners[f.Name] += ", "
+			}
+			owners[f.Name] += sub.name
+		})
+	}
+	return owners
+}
+
+// userConfigPath is the config file read when --config is not given,
+// <user config dir>/pdf-extract/config.yaml.
+func userConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pdf-extract", "config.yaml")
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Loads a config file in YAML (.yaml, .yml), TOML (.toml) or JSON (.json).
+func loadConfig(path string) (*configFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %v", err)
+	}
+
+	raw := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config %s: unsupported file type (want .yaml, .yml, .toml or .json)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %v", path, err)
+	}
+
+	cfg := &configFile{path: path, values: make(map[string][]string, len(raw))}
+	for key, v := range raw {
+		values, err := configValues(v)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %s: %v", path, key, err)
+		}
+		name := strings.ReplaceAll(strings.ToLower(key), "_", "-")
+		if name == "license-key" || name == "license" {
+			if len(values) != 1 {
+				return nil, fmt.Errorf("config %s: %s must be a single value", path, key)
+			}
+			cfg.license = strings.TrimSpace(values[0])
+			continue
+		}
+		cfg.values[name] = values
+	}
+	return cfg, nil
+}
+
+func configValues(v any) ([]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		list = []any{v}
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		switch item := item.(type) {
+		case string:
+			values = append(values, item)
+		case bool:
+			values = append(values, strconv.FormatBool(item))
+		case int, int64, uint64:
+			values = append(values, fmt.Sprint(item))
+		case float64:
+			values = append(values, strconv.FormatFloat(item, 'f', -1, 64))
+		default:
+			return nil, fmt.Errorf("unsupported value %v", item)
+		}
+	}
+	return values, nil
+}
+
+// applySettings fills the flags left off the command line from their
+// environment variables, then from the config file, and records where every
+// value came from. The config file is --config, else PDF_EXTRACT_CONFIG,
+// else the user config file when it exists.
+func applySettings() {
+	onCommandLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		onCommandLine[f.Name] = true
+	})
+
+	path, source := *configPath, "flag"
+	if path == "" {
+		path, source = os.Getenv(envName("config")), "env "+envName("config")
+	}
+	if path == "" {
+		if p := userConfigPath(); p != "" {
+			if _, err := os.Stat(p); err == nil {
+				path, source = p, "user config"
+			}
+		}
+	}
+	if path != "" {
+		cfg, err := loadConfig(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		runConfig = cfg
+		settingSources["config"] = source
+	}
+
+	flag.VisitAll(func(f *flag.Flag) {
+		env := envName(f.Name)
+		var values []string
+		switch {
+		case onCommandLine[f.Name]:
+			settingSources[f.Name] = "flag"
+			return
+		case f.Name == "config" || f.Name == "license":
+			// Looked up on their own, see above and resolveLicenseKey
+			return
+		case os.Getenv(env) != "":
+			values = strings.Split(os.Getenv(env), ";")
+			settingSources[f.Name] = "env " + env
+		case runConfig != nil && runConfig.values[f.Name] != nil:
+			values = runConfig.values[f.Name]
+			settingSources[f.Name] = "config " + runConfig.path
+		default:
+			settingSources[f.Name] = "default"
+			return
+		}
+
+		if _, repeatable := f.Value.(*stringSlice); !repeatable && len(values) != 1 {
+			log.Fatalf("--%s from %s takes a single value, got %d", f.Name, settingSources[f.Name], len(values))
+		}
+		for _, v := range values {
+			if err := f.Value.Set(strings.TrimSpace(v)); err != nil {
+				log.Fatalf("Invalid --%s from %s: %v", f.Name, settingSources[f.Name], err)
+			}
+		}
+	})
+}
+
+// warnUnknownSettings logs the config file settings that are no flag of
+// any subcommand. It runs once logging is set up, so the warnings go to the
+// run's log.
+func warnUnknownSettings() {
+	if runConfig == nil {
+		return
+	}
+	for name := range runConfig.values {
+		switch {
+		case flag.Lookup(name) != nil:
+		case subcommandOf[name] != "":
+			slog.Debug("Ignoring setting of another subcommand", "setting", name, "subcommand", subcommandOf[name],
+				"config", runConfig.path)
+		default:
+			slog.Warn("Ignoring unknown setting", "setting", name, "config", runConfig.path)
+		}
+	}
+}
+
+// printConfig lists every setting with its resolved value and where it
+// came from, for the config print subcommand. Subcommand settings are
+// tagged with their subcommands, e.g. "addr (serve)".
+func printConfig() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+	flag.VisitAll(func(f *flag.Flag) {
+		value, source := f.Value.String(), settingSources[f.Name]
+		switch f.Name {
+		case "config":
+			if runConfig == nil {
+				source = "none"
+			} else {
+				value = runConfig.path
+			}
+		case "license":
+			// Never the key itself
+			key, from, err := resolveLicenseKey()
+			switch {
+			case err == errNoLicenseKey:
+				value, source = "", "not set"
+			case err != nil:
+				value, source = "", err.Error()
+			default:
+				value, source = redactKey(key), from
+			}
+		}
+		name := f.Name
+		if sub := subcommandOf[f.Name]; sub != "" {
+			name += " (" + sub + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, value, source)
+	})
+	w.Flush()
+}
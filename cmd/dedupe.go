@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"pdf-extract/pdfextract"
+)
+
+// Flags of the dedupe subcommand, registered only when it is used.
+var (
+	photoHash      *bool
+	hashDistance   *int
+	duplicatesFile *string
+)
+
+func registerDedupeFlags() {
+	photoHash = flag.Bool("photo-hash", false, "Also hash every photo and report different IDs with near-identical photos.")
+	registerHashDistanceFlag()
+	duplicatesFile = flag.String("duplicates", "", "Where to write the JSON duplicates report. Defaults to duplicates.json in the output directory.")
+}
+
+// Shared by dedupe and diff; registered once when config print registers both.
+func registerHashDistanceFlag() {
+	if flag.Lookup("hash-distance") != nil {
+		return
+	}
+	hashDistance = flag.Int("hash-distance", 6, "Largest number of differing bits (of 64) for two photo hashes to count as the same photo.")
+}
+
+// setupDedupe makes the run index every voter ID instead of writing photos;
+// the photos and records are only read to find where each ID occurs.
+func setupDedupe(opts *pdfextract.Options) *pdfextract.VoterIndex {
+	index := pdfextract.NewVoterIndex()
+	opts.OutputDir = ""
+	opts.Records = true
+	opts.PhotoHash = *photoHash
+	// Files completed by an earlier run (with --state-dir) would be skipped without being indexed
+	opts.Force = true
+	opts.OnPhoto = index.AddPhoto
+	opts.OnRecord = index.AddRecord
+	opts.OnProgress = func(p pdfextract.Progress) {
+		if p.Done {
+			fmt.Printf("\nDone! Indexed %d photo(s) from %s\n", p.Photos, p.Path)
+		}
+	}
+	return index
+}
+
+func writeDuplicates(index *pdfextract.VoterIndex) {
+	dups := index.Duplicates(*hashDistance)
+
+	path := *duplicatesFile
+	if path == "" {
+		path = filepath.Join(*outputDir, "duplicates.json")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+	data, err := json.MarshalIndent(dups, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := pdfextract.WriteFileAtomic(path, append(data, '\n')); err != nil {
+		log.Fatalf("Failed to write duplicates report: %v", err)
+	}
+
+	for _, d := range dups.IDs {
+		slog.Info("Duplicate ID", "voter_id", d.VoterID, "occurrences", len(d.Occurrences), "files", d.Files)
+	}
+	for _, p := range dups.Photos {
+		slog.Info("Similar photos", "voter_ids", p.VoterIDs, "distance", p.Distance)
+	}
+	fmt.Printf("%d voter ID occurrence(s): %d duplicate ID(s)", dups.Occurrences, len(dups.IDs))
+	if *photoHash {
+		fmt.Printf(", %d group(s) of similar photos under different IDs", len(dups.Photos))
+	}
+	fmt.Printf(", report written to %s\n", path)
+}
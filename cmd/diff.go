@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"pdf-extract/pdfextract"
+)
+
+// Flags of the diff subcommand, registered only when it is used.
+var diffFile *string
+
+func registerDiffFlags() {
+	registerHashDistanceFlag()
+	diffFile = flag.String("diff", "", "Where to write the JSON diff; the HTML summary goes next to it. Defaults to diff.json in the output directory.")
+}
+
+// Parses the diff arguments, which may mix flags with the old and new
+// edition (a PDF, directory, glob or @list each).
+func parseDiffArgs(args []string) []string {
+	var editions []string
+	for {
+		flag.CommandLine.Parse(args)
+		rest := flag.Args()
+		if len(rest) == 0 {
+			break
+		}
+		editions = append(editions, rest[0])
+		args = rest[1:]
+	}
+	if len(editions) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: diff [flags] OLD NEW")
+		os.Exit(2)
+	}
+	return editions
+}
+
+// runDiff extracts both editions, the old one into <output>/old and the new
+// one into <output>/new, and indexes the voter IDs of each. It returns nil
+// indexes when the run is aborted before extracting.
+func runDiff(ctx context.Context, opts pdfextract.Options, editions []string, report *pdfextract.Report) (old, new *pdfextract.VoterIndex) {
+	var files [2][]string
+	for i, edition := range editions {
+		files[i] = collectInputFiles([]string{edition}, report)
+	}
+	report.Files = len(files[0]) + len(files[1]) + report.FailedFiles
+	if opts.OnError == pdfextract.AbortRun && report.FailureCount() > 0 {
+		fmt.Println("Aborting: some inputs could not be used")
+		return nil, nil
+	}
+
+	var indexes [2]*pdfextract.VoterIndex
+	for i, side := range []string{"old", "new"} {
+		index := pdfextract.NewVoterIndex()
+		indexes[i] = index
+
+		sideOpts := opts
+		sideOpts.OutputDir = filepath.Join(opts.OutputDir, side)
+		sideOpts.Records = true
+		sideOpts.PhotoHash = true
+		// Files completed by an earlier run would be skipped without being indexed
+		sideOpts.Force = true
+		sideOpts.OnPhoto = index.AddPhoto
+		sideOpts.OnRecord = index.AddRecord
+		sideOpts.OnProgress = func(p pdfextract.Progress) {
+			if p.Done {
+				fmt.Printf("\nDone! Extracted %d image(s) from %s (%s edition)\n", p.Photos, p.Path, side)
+			}
+		}
+		ex, err := pdfextract.New(sideOpts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		slog.Info("Extracting edition", "edition", side, "files", len(files[i]))
+		if err := ex.Run(ctx, files[i], report); err != nil {
+			break
+		}
+	}
+	return indexes[0], indexes[1]
+}
+
+func writeDiff(old, new *pdfextract.VoterIndex) {
+	diff := pdfextract.DiffRolls(old.Occurrences(), new.Occurrences(), *hashDistance)
+
+	jsonPath := *diffFile
+	if jsonPath == "" {
+		jsonPath = filepath.Join(*outputDir, "diff.json")
+	}
+	htmlPath := jsonPath[:len(jsonPath)-len(filepath.Ext(jsonPath))] + ".html"
+	if err := os.MkdirAll(filepath.Dir(jsonPath), os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := pdfextract.WriteFileAtomic(jsonPath, append(data, '\n')); err != nil {
+		log.Fatalf("Failed to write diff: %v", err)
+	}
+	if err := writeDiffHTML(htmlPath, diff); err != nil {
+		log.Fatalf("Failed to write diff summary: %v", err)
+	}
+
+	slog.Info("Compared editions", "added", len(diff.Added), "removed", len(diff.Removed), "changed", len(diff.Changed))
+	fmt.Printf("%d voter(s) added, %d removed, %d changed; diff written to %s and %s\n",
+		len(diff.Added), len(diff.Removed), len(diff.Changed), jsonPath, htmlPath)
+}
+
+func writeDiffHTML(path string, diff pdfextract.RollDiff) error {
+	dir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	funcs := template.FuncMap{
+		// Photo links relative to the page, so the output folder can be moved
+		"photo": func(o pdfextract.Occurrence) string {
+			if o.PhotoPath == "" {
+				return ""
+			}
+			abs, err := filepath.Abs(o.PhotoPath)
+			if err != nil {
+				return ""
+			}
+			rel, err := filepath.Rel(dir, abs)
+			if err != nil {
+				return ""
+			}
+			return filepath.ToSlash(rel)
+		},
+		"name": func(o pdfextract.Occurrence) string {
+			if o.Record == nil {
+				return ""
+			}
+			return o.Record.Name
+		},
+		"base": filepath.Base,
+	}
+	tpl, err := template.New("diff").Funcs(funcs).Parse(diffHTML)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, diff); err != nil {
+		return err
+	}
+	return pdfextract.WriteFileAtomic(path, buf.Bytes())
+}
+
+const diffHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Voter roll diff</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+th { background: #eee; }
+img { height: 80px; }
+.old { color: #a00; }
+.new { color: #070; }
+</style>
+</head>
+<body>
+<h1>Voter roll diff</h1>
+<p>{{.OldIDs}} voter ID(s) in the old edition, {{.NewIDs}} in the new one:
+{{len .Added}} added, {{len .Removed}} removed, {{len .Changed}} changed.
+Photos count as changed when their hashes differ by more than {{.MaxDistance}} of 64 bits;
+ages are expected to have risen by {{.AgeGap}} year(s), give or take one.</p>
+
+{{define "voters"}}
+<table>
+<tr><th>Voter ID</th><th>Name</th><th>File</th><th>Page</th><th>Photo</th></tr>
+{{range .}}<tr><td>{{.VoterID}}</td><td>{{name .}}</td><td>{{base .File}}</td><td>{{.Page}}</td>
+<td>{{with photo .}}<img src="{{.}}">{{end}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Added ({{len .Added}})</h2>
+{{template "voters" .Added}}
+
+<h2>Removed ({{len .Removed}})</h2>
+{{template "voters" .Removed}}
+
+<h2>Changed ({{len .Changed}})</h2>
+<table>
+<tr><th>Voter ID</th><th>Changes</th><th>Old</th><th>New</th></tr>
+{{range .Changed}}<tr><td>{{.VoterID}}</td>
+<td>{{if .PhotoChanged}}photo ({{.PhotoDistance}} bits)<br>{{end}}
+{{range .Fields}}{{.Field}}: <span class="old">{{.Old}}</span> &rarr; <span class="new">{{.New}}</span><br>{{end}}</td>
+<td>{{base .Old.File}} page {{.Old.Page}}<br>{{with photo .Old}}<img src="{{.}}">{{end}}</td>
+<td>{{base .New.File}} page {{.New.Page}}<br>{{with photo .New}}<img src="{{.}}">{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
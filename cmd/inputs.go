@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"pdf-extract/pdfextract"
+)
+
+/* ---------- input expansion ---------- */
+
+// Turns the --input values into a sorted, de-duplicated list of PDF files.
+// Each value may be a file, a directory (searched recursively for *.pdf), a
+// glob pattern, or @list.txt naming one input per line. Inputs that cannot be
+// used are recorded in the report as failures; files that are not PDFs are
+// recorded as skipped.
+func collectInputFiles(args []string, report *pdfextract.Report) []string {
+	var expanded []string
+	for _, arg := range args {
+		expanded = append(expanded, expandInput(arg, make(map[string]bool), report)...)
+	}
+
+	files := verifyInputFiles(expanded, report)
+	sort.Strings(files)
+
+	seen := make(map[string]bool, len(files))
+	pdfs := make([]string, 0, len(files))
+	for _, f := range files {
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		if !isPDFFile(f) {
+			fmt.Printf("WARNING: skipping %s, not a PDF file\n", f)
+			report.AddSkippedInput(f, "not a PDF file")
+			continue
+		}
+		pdfs = append(pdfs, f)
+	}
+	return pdfs
+}
+
+// listing holds the absolute paths of the list files being read, to catch
+// lists that name themselves directly or through other lists.
+func expandInput(arg string, listing map[string]bool, report *pdfextract.Report) []string {
+	if listFile, ok := strings.CutPrefix(arg, "@"); ok {
+		return expandListFile(listFile, listing, report)
+	}
+
+	if strings.ContainsAny(arg, "*?[") {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			report.AddFailure(arg, 0, pdfextract.WithCategory(pdfextract.CategoryInput, fmt.Errorf("invalid glob pattern: %v", err)))
+			return nil
+		}
+		if len(matches) == 0 {
+			fmt.Printf("WARNING: %s matched no files\n", arg)
+		}
+		var files []string
+		for _, m := range matches {
+			files = append(files, expandPath(m, report)...)
+		}
+		return files
+	}
+
+	return expandPath(arg, report)
+}
+
+// A directory expands to every *.pdf below it, anything else to itself.
+func expandPath(path string, report *pdfextract.Report) []string {
+	info, err := os.Stat(path)
+	if err != nil || !info.IsDir() {
+		return []string{path}
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".pdf") {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		report.AddFailure(path, 0, pdfextract.WithCategory(pdfextract.CategoryInput, fmt.Errorf("could not read input directory: %v", err)))
+	}
+	if len(files) == 0 {
+		fmt.Printf("WARNING: no PDF files found in %s\n", path)
+	}
+	return files
+}
+
+// Reads one input per line; blank lines and lines starting with # are ignored.
+// Relative paths are taken relative to the current directory.
+func expandListFile(path string, listing map[string]bool, report *pdfextract.Report) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if listing[abs] {
+		report.AddFailure(path, 0, pdfextract.WithCategory(pdfextract.CategoryInput, fmt.Errorf("input list names itself, directly or through other lists")))
+		return nil
+	}
+	listing[abs] = true
+	defer delete(listing, abs)
+
+	f, err := os.Open(path)
+	if err != nil {
+		report.AddFailure(path, 0, pdfextract.WithCategory(pdfextract.CategoryInput, fmt.Errorf("could not open input list: %v", err)))
+		return nil
+	}
+	defer f.Close()
+
+	var files []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		files = append(files, expandInput(line, listing, report)...)
+	}
+	if err := scanner.Err(); err != nil {
+		report.AddFailure(path, 0, pdfextract.WithCategory(pdfextract.CategoryInput, fmt.Errorf("could not read input list: %v", err)))
+	}
+	return files
+}
+
+// Checks for the %PDF- signature, which readers accept anywhere in the first 1KB.
+func isPDFFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header := make([]byte, 1024)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false
+	}
+	return bytes.Contains(header[:n], []byte("%PDF-"))
+}
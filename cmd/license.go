@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+/* ---------- license ---------- */
+
+// Environment variable holding the UniDoc license key.
+const licenseEnv = "UNIDOC_LICENSE_API_KEY"
+
+var errNoLicenseKey = errors.New("no UniDoc license key set")
+
+// Looks the license key up in the --license flag, the UNIDOC_LICENSE_API_KEY
+// environment variable, the --license-file key file and license_key in the
+// config file, in that order. It returns the key and where it was found.
+func resolveLicenseKey() (key, source string, err error) {
+	if *licenseKey != "" {
+		return *licenseKey, "--license", nil
+	}
+	if k := strings.TrimSpace(os.Getenv(licenseEnv)); k != "" {
+		return k, licenseEnv, nil
+	}
+	if *licenseFile != "" {
+		data, err := os.ReadFile(*licenseFile)
+		if err != nil {
+			return "", "", fmt.Errorf("could not read license file: %v", err)
+		}
+		k := strings.TrimSpace(string(data))
+		if k == "" {
+			return "", "", fmt.Errorf("license file %s is empty", *licenseFile)
+		}
+		return k, *licenseFile, nil
+	}
+	if runConfig != nil && runConfig.license != "" {
+		return runConfig.license, runConfig.path, nil
+	}
+	return "", "", errNoLicenseKey
+}
+
+// Shows only the last 4 characters of a key, for logs.
+func redactKey(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("*", len(key))
+	}
+	return strings.Repeat("*", 8) + key[len(key)-4:]
+}
+
+// initLicense sets up the unipdf license. It fails when no key is set or
+// the metering service cannot be reached, as on air-gapped machines; a key
+// file that cannot be read stops the program.
+func initLicense() error {
+	key, source, err := resolveLicenseKey()
+	if err == errNoLicenseKey {
+		return err
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	slog.Info("Using UniDoc license key", "key", redactKey(key), "source", source)
+	if err := license.SetMeteredKey(key); err != nil {
+		return fmt.Errorf("failed to set license key: %s", strings.ReplaceAll(err.Error(), key, redactKey(key)))
+	}
+	return nil
+}
+
+// Tells where a license key can be set, for startup errors.
+func licenseHelp() string {
+	return "pass --license, set " + licenseEnv + ", point --license-file at a file holding the key" +
+		" or set license_key in the --config file"
+}
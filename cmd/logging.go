@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+)
+
+/* ---------- logging ---------- */
+
+// setupLogging sends all logging through a slog handler writing text or
+// JSON at --log-level to --log-file: "-" for stderr, or by default a new
+// <unix time>_app.log in the working directory. What is still logged with
+// the log package (fatal errors) is logged at error level, and also printed
+// to stderr when the log goes to a file. It returns a function that closes
+// the log file.
+func setupLogging() func() {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(*logLevel)); err != nil {
+		log.Fatalf("Invalid --log-level %q (want debug, info, warn or error)", *logLevel)
+	}
+	if *logFormat != "text" && *logFormat != "json" {
+		log.Fatalf("Invalid --log-format %q (want text or json)", *logFormat)
+	}
+
+	out, closeLog := os.Stderr, func() {}
+	if *logFile != "-" {
+		name := *logFile
+		if name == "" {
+			name = fmt.Sprintf("%d_app.log", time.Now().Unix())
+			fmt.Printf("created log file :%s\n", name)
+		}
+		file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out, closeLog = file, func() { file.Close() }
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(out, handlerOpts)
+	if *logFormat == "json" {
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	}
+	slog.SetDefault(slog.New(handler))
+	slog.SetLogLoggerLevel(slog.LevelError)
+	if out != os.Stderr {
+		// Bad flags and license errors stop the run, so they have to be seen
+		log.SetOutput(io.MultiWriter(os.Stderr, log.Writer()))
+	}
+	return closeLog
+}
@@ -1,39 +1,46 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"path/filepath"
-	"sync"
-
-	// "image/jpeg"
 	"log"
+	"log/slog"
 	"os"
-
-	// "path/filepath"
-
-	// "sort"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"syscall"
 	"time"
 
-	"github.com/unidoc/unipdf/v4/common/license"
-	// "github.com/unidoc/unipdf/v4/extractor"
-	// "github.com/unidoc/unipdf/v4/model"
-)
-
-const (
-	UNIDOC_LICENSE_API_KEY = "38311761653b7d673d05b475e0a29fbca0d87f42b623d9f7dd016f900dbdcdd4"
-	// INPUT_FILE             = "input/sample.pdf"
-	INPUT_FILE = "input/1_कोशी प्रदेश_4_झापा_5031_कमल गाउँपालिका.pdf"
-	OUTPUT_DIR = "output/"
-
+	"pdf-extract/pdfextract"
 )
 
 var (
-	licenseKey = flag.String("license", UNIDOC_LICENSE_API_KEY, "UniDoc license key (or set UNIDOC_LICENSE_API_KEY env var)")
-	outputDir  = flag.String("output", "output/", "Output directory.")
-	inputFiles stringSlice
-	
-
+	configPath   = flag.String("config", "", "Settings file (.yaml, .toml or .json) with flag names as keys, e.g. workers: 4 or input: [a.pdf, rolls/]. Flags override PDF_EXTRACT_<FLAG> env vars, which override the file. Defaults to the user config file pdf-extract/config.yaml when it exists.")
+	licenseKey   = flag.String("license", "", "UniDoc license key. Otherwise read from the "+licenseEnv+" env var, --license-file or license_key in the --config file.")
+	licenseFile  = flag.String("license-file", "", "File holding the UniDoc license key, used when neither --license nor "+licenseEnv+" is set.")
+	outputDir    = flag.String("output", "output/", "Output directory.")
+	outputLayout = flag.String("output-layout", pdfextract.DefaultOutputLayout, "Folders under the output directory for each file's photos, from {province}, {province_number}, {district}, {district_code}, {municipality}, {municipality_code}, {ward}, {polling_centre} and {file}.")
+	templateFile = flag.String("template", "", "Layout template (.yaml or .json) describing the roll format. Defaults to the built-in layout.")
+	workers      = flag.Int("workers", runtime.NumCPU(), "Number of pages processed in parallel across all input files.")
+	maxMemoryMB  = flag.Int("max-memory", 2048, "Approximate memory (MB) open documents may use; limits how many files and page readers are open at once.")
+	onError      = flag.String("on-error", string(pdfextract.SkipPage), "What to do when a page fails: skip-page, skip-file or abort.")
+	onCollision  = flag.String("on-collision", string(pdfextract.CollisionOverwrite), "What to do when two photos get the same file name: overwrite, suffix (<id>_2.jpg), skip or fail. Collisions are listed in the run report.")
+	maxFailures  = flag.Int("max-failures", 0, "Exit with a nonzero status when more than this many files/pages failed.")
+	reportFile   = flag.String("report", "", "Where to write the JSON run report. Defaults to run_report.json in the output directory.")
+	stateDir     = flag.String("state-dir", "", "Where checkpoints of finished files and pages are kept. Defaults to .checkpoints in the output directory.")
+	force        = flag.Bool("force", false, "Reprocess every file and page, ignoring checkpoints from earlier runs.")
+	format       = flag.String("format", string(pdfextract.FormatJPEG), "Photo format: jpeg, png, webp or original. JPEG photos embedded in the PDF are written unchanged for jpeg and original.")
+	jpegQuality  = flag.Int("jpeg-quality", 90, "JPEG quality (1-100) for photos that have to be re-encoded.")
+	records      = flag.Bool("records", false, "Also write voter records (records.csv and records.jsonl) for each input file.")
+	debugOverlay = flag.Bool("debug-overlay", false, "Also draw every page with images to debug/page-NNN.png in the file's output folder, with boxes for image and ID marks, pairing lines and skipped header images.")
+	logFile      = flag.String("log-file", "", "Where to write the log; - for stderr. Defaults to a new <unix time>_app.log in the working directory.")
+	logLevel     = flag.String("log-level", "info", "Least severe log level written: debug, info, warn or error.")
+	logFormat    = flag.String("log-format", "text", "Log format: text (key=value pairs) or json. Events carry file, page and image_index fields where they apply.")
+	backend      = flag.String("backend", "", "Libraries to read the PDFs with: unipdf, ledongthuc (text and layout; images still from unipdf), offline (no license or network needed) or a mix like text=ledongthuc,layout=unipdf,images=offline. Defaults to "+pdfextract.DefaultBackend.String()+", or offline when no license can be set up.")
+	inputFiles   stringSlice
+	idRules      stringSlice
 )
 
 type stringSlice []string
@@ -47,83 +54,270 @@ func (s *stringSlice) Set(value string) error {
 	return nil
 }
 
-func initLicense() {
-	key := *licenseKey
-	if err := license.SetMeteredKey(key); err != nil {
-		log.Fatalf("Failed to set license key: %v", err)
-		panic(err)
-	}
-}
-
 func main() {
+	args := os.Args[1:]
+	serve := len(args) > 0 && args[0] == "serve"
+	dedupe := len(args) > 0 && args[0] == "dedupe"
+	diff := len(args) > 0 && args[0] == "diff"
+	configPrint := len(args) > 1 && args[0] == "config" && args[1] == "print"
+	subcommandOf = findSubcommandSettings()
+	switch {
+	case serve:
+		registerServeFlags()
+		args = args[1:]
+	case dedupe:
+		registerDedupeFlags()
+		args = args[1:]
+	case diff:
+		registerDiffFlags()
+		args = args[1:]
+	case configPrint:
+		// Every subcommand's settings, as one config file may serve them all
+		for _, sub := range subcommandFlags {
+			sub.register()
+		}
+		args = args[2:]
+	}
 
-	flag.Var(&inputFiles, "input", "Input PDF file (can be used multiple times)")
-
-	flag.Parse()
+	flag.Var(&inputFiles, "input", "Input PDF file, directory, glob pattern or @list.txt (can be used multiple times)")
+	flag.Var(&idRules, "id-rule", "Voter ID rule replacing the template's id_validators: length=8, range=MIN-MAX, prefix=50,51, checksum=luhn|mod11 or not-year[=MIN-MAX] (can be used multiple times)")
 
-	initLicense()
-	validFiles := verifyInputFilesStrict(inputFiles)
+	var editions []string
+	if diff {
+		editions = parseDiffArgs(args)
+	} else {
+		flag.CommandLine.Parse(args)
+	}
+	applySettings()
+	if configPrint {
+		warnUnknownSettings()
+		printConfig()
+		return
+	}
 
-	logFileName := fmt.Sprintf("%d_app.log", time.Now().Unix())
-	fmt.Printf("created log file :%s\n", logFileName)
-	file, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		log.Fatal(err)
+	if !serve && !diff && len(inputFiles) == 0 {
+		fmt.Fprintln(os.Stderr, "No input files provided")
+		fmt.Fprintln(os.Stderr, "usage: [dedupe] --input FILE|DIR|GLOB|@LIST [flags], see -help")
+		os.Exit(2)
 	}
+	// Before anything is logged, so license and backend messages follow
+	// --log-file, --log-format and --log-level too
+	closeLog := setupLogging()
+	defer closeLog()
+	warnUnknownSettings()
 
-	defer file.Close()
+	opts := extractSettings()
 
-	log.SetOutput(file)
-	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+	ctx := interruptContext()
+	if serve {
+		runServer(ctx, opts)
+		return
+	}
 
-	log.Println("starting ...")
+	slog.Info("Starting batch")
 	startTime := time.Now()
 
-	wg := sync.WaitGroup{}
-	for _, input := range validFiles {
-		wg.Add(1)
-		go func(input string) {
-			defer wg.Done()
-			if err := extractImagesWithIDNames_v1_more(input, *outputDir); err != nil {
-				errStr := fmt.Sprintf("ERROR: error encountered in file %s : %v \n\n", input, err)
-				log.Print(errStr)
-				fmt.Print(errStr)
+	report := pdfextract.NewReport(opts.OnError)
+	report.OnFailure = printFailure
+	var index, oldIndex, newIndex *pdfextract.VoterIndex
+	if diff {
+		oldIndex, newIndex = runDiff(ctx, opts, editions, report)
+	} else {
+		validFiles := collectInputFiles(inputFiles, report)
+		// Every failure so far is an input that never made it into validFiles
+		report.Files = len(validFiles) + report.FailedFiles
+
+		opts.OnProgress = func(p pdfextract.Progress) {
+			if p.Done {
+				fmt.Printf("\nDone! Extracted %d image(s) from %s to %s\n", p.Photos, p.Path, *outputDir)
 			}
-		}(input)
+		}
+		if dedupe {
+			index = setupDedupe(&opts)
+		}
+		ex, err := pdfextract.New(opts)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if opts.OnError == pdfextract.AbortRun && report.FailureCount() > 0 {
+			fmt.Println("Aborting: some inputs could not be used")
+		} else {
+			ex.Run(ctx, validFiles, report)
+		}
 	}
-	wg.Wait()
-	// err = extractImagesWithIDNames_v1_more(INPUT_FILE, OUTPUT_DIR)
-	// if err != nil {
-	// 	fmt.Printf("Error: %v\n", err)
-	// 	os.Exit(1)
-	// }
 
 	endTime := time.Since(startTime)
 	fmt.Printf("Completed batch %.2f seconds\n", endTime.Seconds())
+
+	reportPath := *reportFile
+	if reportPath == "" {
+		reportPath = filepath.Join(*outputDir, "run_report.json")
+	}
+	if err := os.MkdirAll(filepath.Dir(reportPath), os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+	if err := report.Write(reportPath); err != nil {
+		log.Fatalf("Failed to write run report: %v", err)
+	}
+	failures := report.FailureCount()
+	if report.SkippedFiles > 0 {
+		fmt.Printf("%d file(s) skipped, already completed by an earlier run\n", report.SkippedFiles)
+	}
+	if len(report.SkippedInputs) > 0 {
+		fmt.Printf("%d input(s) skipped, not PDF files\n", len(report.SkippedInputs))
+	}
+	fmt.Printf("%d failure(s) in %d of %d file(s), report written to %s\n", failures, report.FailedFiles, report.Files, reportPath)
+	if len(report.Collisions) > 0 {
+		fmt.Printf("%d photo file name collision(s), see the report\n", len(report.Collisions))
+	}
+	if index != nil {
+		writeDuplicates(index)
+	}
+	if oldIndex != nil && newIndex != nil && !report.Interrupted {
+		writeDiff(oldIndex, newIndex)
+	}
+
+	if report.Interrupted {
+		fmt.Printf("Interrupted: %d file(s) completed and %d page(s) written; run again with the same options to resume\n",
+			report.CompletedFiles, report.PagesWritten)
+		slog.Warn("Interrupted", "completed_files", report.CompletedFiles, "pages_written", report.PagesWritten)
+		closeLog()
+		os.Exit(130)
+	}
+	if failures > *maxFailures {
+		slog.Error("Too many failures", "failures", failures, "max_failures", *maxFailures)
+		closeLog()
+		os.Exit(1)
+	}
 }
 
-/* ---------- strict file verification ---------- */
+// interruptContext is cancelled on the first SIGINT or SIGTERM, which lets the
+// run finish the photos being written and save its manifests. A second
+// signal kills the process as usual.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		signal.Stop(sigs)
+		slog.Warn("Received signal, stopping", "signal", sig)
+		fmt.Printf("\nReceived %v: finishing the photos being written (press Ctrl-C again to quit at once)\n", sig)
+		cancel()
+	}()
+	return ctx
+}
 
-func verifyInputFilesStrict(files []string) []string {
-	if len(files) == 0 {
-		panic("No input files provided")
+func printFailure(f pdfextract.Failure) {
+	if f.Page > 0 {
+		fmt.Printf("ERROR: error encountered in file %s page %d : %v \n\n", f.File, f.Page, f.Error)
+	} else {
+		fmt.Printf("ERROR: error encountered in file %s : %v \n\n", f.File, f.Error)
+	}
+}
+
+// Turns the extraction flags into library options, sets up the license and
+// loads the template.
+func extractSettings() pdfextract.Options {
+	policy, err := pdfextract.ParseErrorPolicy(*onError)
+	if err != nil {
+		log.Fatal(err)
 	}
+	collision, err := pdfextract.ParseCollisionPolicy(*onCollision)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pdfBackend, err := pdfextract.ParseBackend(*backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	photoFormat, err := pdfextract.ParseFormat(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *jpegQuality < 1 || *jpegQuality > 100 {
+		log.Fatalf("--jpeg-quality must be between 1 and 100, got %d", *jpegQuality)
+	}
+	if err := pdfextract.ValidateOutputLayout(*outputLayout); err != nil {
+		log.Fatal(err)
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	// Without a license, extract offline unless a licensed backend was asked for
+	if pdfBackend.NeedsLicense() {
+		switch err := initLicense(); {
+		case err == nil:
+		case *backend == "":
+			pdfBackend = pdfextract.OfflineBackend
+			slog.Warn("Extracting offline", "reason", err, "backend", pdfBackend)
+		case err == errNoLicenseKey:
+			log.Fatalf("--backend %s needs a UniDoc license key: %s, or use --backend offline", *backend, licenseHelp())
+		default:
+			log.Fatalf("--backend %s needs a UniDoc license: %v", *backend, err)
+		}
+	}
+	tpl := pdfextract.DefaultTemplate()
+	if *templateFile != "" {
+		if tpl, err = pdfextract.LoadTemplate(*templateFile); err != nil {
+			log.Fatalf("Failed to load template: %v", err)
+		}
+	}
+	if len(idRules) > 0 {
+		specs := make([]pdfextract.ValidatorSpec, 0, len(idRules))
+		for _, r := range idRules {
+			spec, err := pdfextract.ParseIDRule(r)
+			if err != nil {
+				log.Fatalf("Invalid --id-rule: %v", err)
+			}
+			specs = append(specs, spec)
+		}
+		if err := tpl.SetIDValidators(specs); err != nil {
+			log.Fatalf("Invalid --id-rule: %v", err)
+		}
+	}
+	return pdfextract.Options{
+		OutputDir:    *outputDir,
+		OutputLayout: *outputLayout,
+		Records:      *records,
+		DebugOverlay: *debugOverlay,
+		Backend:      pdfBackend,
+		Template:     tpl,
+		Format:       photoFormat,
+		JPEGQuality:  *jpegQuality,
+		Workers:      *workers,
+		MaxMemory:    int64(*maxMemoryMB) << 20,
+		OnError:      policy,
+		OnCollision:  collision,
+		StateDir:     *stateDir,
+		Force:        *force,
+	}
+}
+
+/* ---------- input file verification ---------- */
 
+// Resolves every input to an absolute path. Inputs that do not exist or are
+// not regular files are recorded in the report and left out.
+func verifyInputFiles(files []string, report *pdfextract.Report) []string {
 	validFiles := make([]string, 0, len(files))
 
 	for _, f := range files {
 		abs, err := filepath.Abs(f)
 		if err != nil {
-			panic(fmt.Sprintf("Invalid file path: %s (%v)", f, err))
+			report.AddFailure(f, 0, pdfextract.WithCategory(pdfextract.CategoryInput, fmt.Errorf("invalid file path: %v", err)))
+			continue
 		}
 
 		info, err := os.Stat(abs)
 		if err != nil {
-			panic(fmt.Sprintf("Input file does not exist: %s", abs))
+			report.AddFailure(abs, 0, pdfextract.WithCategory(pdfextract.CategoryInput, fmt.Errorf("input file does not exist")))
+			continue
 		}
 
 		if info.IsDir() {
-			panic(fmt.Sprintf("Input path is a directory, not a file: %s", abs))
+			report.AddFailure(abs, 0, pdfextract.WithCategory(pdfextract.CategoryInput, fmt.Errorf("input path is a directory, not a file")))
+			continue
 		}
 
 		validFiles = append(validFiles, abs)
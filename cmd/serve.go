@@ -0,0 +1,498 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"pdf-extract/pdfextract"
+)
+
+// Flags of the serve subcommand, registered only when it is used.
+var (
+	serveAddr   *string
+	maxUploadMB *int
+	maxJobs     *int
+	jobTTL      *time.Duration
+)
+
+func registerServeFlags() {
+	serveAddr = flag.String("addr", ":8080", "Address the extraction service listens on.")
+	maxUploadMB = flag.Int("max-upload", 100, "Largest PDF upload the service accepts, in MB.")
+	maxJobs = flag.Int("jobs", 2, "Number of uploaded rolls extracted at the same time; later uploads wait in a queue.")
+	jobTTL = flag.Duration("job-ttl", 24*time.Hour, "How long a finished job and its files are kept, e.g. 72h; 0 keeps them until DELETE /jobs/{id}.")
+}
+
+type jobStatus string
+
+const (
+	jobQueued  jobStatus = "queued"
+	jobRunning jobStatus = "running"
+	jobDone    jobStatus = "done"
+	jobFailed  jobStatus = "failed"
+	// The service was shut down before the job finished.
+	jobCancelled jobStatus = "cancelled"
+)
+
+// serveJob is one uploaded roll and its extraction, as reported by GET /jobs/{id}.
+type serveJob struct {
+	ID        string               `json:"id"`
+	Status    jobStatus            `json:"status"`
+	File      string               `json:"file"`
+	Created   time.Time            `json:"created"`
+	Started   *time.Time           `json:"started,omitempty"`
+	Finished  *time.Time           `json:"finished,omitempty"`
+	Pages     int                  `json:"pages"`
+	PagesDone int                  `json:"pages_done"`
+	Photos    int                  `json:"photos"`
+	Failures  []pdfextract.Failure `json:"failures"`
+
+	dir   string
+	input string
+	// result.zip responses being written; the job is not deleted meanwhile
+	downloads int
+}
+
+// server runs uploaded rolls through the same batch extraction as the CLI.
+// Every job gets its own folder under the output directory; jobs are kept
+// in memory only, so their status is lost when the service restarts.
+// Finished jobs are deleted with their folder once they are older than the
+// TTL, and so are folders left by earlier runs of the service. A job is
+// registered before its upload is saved, so its folder is never taken for
+// one of those.
+type server struct {
+	ctx       context.Context
+	running   sync.WaitGroup
+	opts      pdfextract.Options
+	budget    *pdfextract.MemoryBudget
+	slots     chan struct{}
+	maxUpload int64
+	root      string
+	ttl       time.Duration
+
+	mu   sync.Mutex
+	jobs map[string]*serveJob
+}
+
+// runServer serves until ctx is cancelled, then stops accepting uploads and
+// waits for running jobs to write what they have finished.
+func runServer(ctx context.Context, opts pdfextract.Options) {
+	if *maxJobs < 1 {
+		*maxJobs = 1
+	}
+	s := &server{
+		ctx:       ctx,
+		opts:      opts,
+		budget:    pdfextract.NewMemoryBudget(opts.MaxMemory),
+		slots:     make(chan struct{}, *maxJobs),
+		maxUpload: int64(*maxUploadMB) << 20,
+		root:      filepath.Join(opts.OutputDir, "jobs"),
+		ttl:       *jobTTL,
+		jobs:      make(map[string]*serveJob),
+	}
+	if err := os.MkdirAll(s.root, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+	if s.ttl > 0 {
+		go s.expireJobs()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /extract", s.handleExtract)
+	mux.HandleFunc("GET /jobs/{id}", s.handleJob)
+	mux.HandleFunc("GET /jobs/{id}/result.zip", s.handleResult)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleDelete)
+
+	srv := &http.Server{
+		Addr:              *serveAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Shutting down", "error", err)
+		}
+	}()
+
+	slog.Info("Serving", "addr", *serveAddr, "jobs", s.root)
+	fmt.Printf("Serving on %s\n", *serveAddr)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	s.running.Wait()
+	slog.Info("Service stopped")
+	fmt.Println("Service stopped")
+}
+
+// Accepts a multipart upload with the PDF in the "file" field and queues it.
+func (s *server) handleExtract(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	upload, header, err := r.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload larger than %d MB", *maxUploadMB))
+			return
+		}
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("expected a PDF in form field \"file\": %v", err))
+		return
+	}
+	defer upload.Close()
+
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// Keep the uploaded name, the roll metadata is read from it
+	name := filepath.Base(filepath.Clean("/" + strings.ReplaceAll(header.Filename, `\`, "/")))
+	if name == "/" || name == "." {
+		name = "upload.pdf"
+	}
+	if !strings.EqualFold(filepath.Ext(name), ".pdf") {
+		name += ".pdf"
+	}
+
+	job := &serveJob{
+		ID:       id,
+		Status:   jobQueued,
+		File:     name,
+		Created:  time.Now(),
+		Failures: []pdfextract.Failure{},
+		dir:      filepath.Join(s.root, id),
+	}
+	job.input = filepath.Join(job.dir, "input", name)
+	s.mu.Lock()
+	s.jobs[id] = job
+	s.mu.Unlock()
+	if err := saveUpload(upload, job.input); err != nil {
+		s.discard(job)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("upload larger than %d MB", *maxUploadMB))
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !isPDFFile(job.input) {
+		s.discard(job)
+		writeError(w, http.StatusUnsupportedMediaType, "upload is not a PDF")
+		return
+	}
+
+	slog.Info("Job queued", "job", id, "upload", name)
+	s.running.Add(1)
+	go s.run(job)
+
+	w.Header().Set("Location", "/jobs/"+id)
+	writeJSON(w, http.StatusAccepted, s.snapshot(job))
+}
+
+// Drops a job whose upload could not be used.
+func (s *server) discard(job *serveJob) {
+	s.mu.Lock()
+	delete(s.jobs, job.ID)
+	s.mu.Unlock()
+	os.RemoveAll(job.dir)
+}
+
+func saveUpload(src io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
+	job := s.job(r.PathValue("id"))
+	if job == nil {
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.snapshot(job))
+}
+
+// Streams the photos, manifest, records and run report of a finished job.
+func (s *server) handleResult(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	job := s.jobs[r.PathValue("id")]
+	if job == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	if st := job.Status; st == jobQueued || st == jobRunning {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, fmt.Sprintf("job is %s", st))
+		return
+	}
+	job.downloads++
+	s.mu.Unlock()
+	defer s.update(job, func(j *serveJob) { j.downloads-- })
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.ID+".zip"))
+	if err := writeZip(w, filepath.Join(job.dir, "output")); err != nil {
+		// Headers are gone already, all that is left is to log it
+		slog.Warn("Could not write result.zip", "job", job.ID, "error", err)
+	}
+}
+
+// Deletes a finished job and its files.
+func (s *server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	s.mu.Lock()
+	job := s.jobs[id]
+	if job == nil {
+		s.mu.Unlock()
+		writeError(w, http.StatusNotFound, "no such job")
+		return
+	}
+	if job.Status == jobQueued || job.Status == jobRunning {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, fmt.Sprintf("job is %s", job.Status))
+		return
+	}
+	if job.downloads > 0 {
+		s.mu.Unlock()
+		writeError(w, http.StatusConflict, "result.zip is being downloaded")
+		return
+	}
+	delete(s.jobs, id)
+	s.mu.Unlock()
+
+	if err := os.RemoveAll(job.dir); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	slog.Info("Job deleted", "job", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// expireJobs deletes expired jobs every minute (or TTL, when shorter) until
+// the service stops.
+func (s *server) expireJobs() {
+	ticker := time.NewTicker(min(s.ttl, time.Minute))
+	defer ticker.Stop()
+	for {
+		s.deleteExpired(time.Now())
+		select {
+		case <-ticker.C:
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// deleteExpired deletes the jobs that finished more than the TTL ago, unless
+// their result is being downloaded, and the job folders not known to this
+// run that were last changed as long ago.
+func (s *server) deleteExpired(now time.Time) {
+	var expired []*serveJob
+	known := make(map[string]bool)
+	s.mu.Lock()
+	for id, job := range s.jobs {
+		if job.Finished != nil && now.Sub(*job.Finished) > s.ttl && job.downloads == 0 {
+			delete(s.jobs, id)
+			expired = append(expired, job)
+			continue
+		}
+		known[id] = true
+	}
+	s.mu.Unlock()
+
+	for _, job := range expired {
+		if err := os.RemoveAll(job.dir); err != nil {
+			slog.Warn("Could not delete expired job", "job", job.ID, "error", err)
+			continue
+		}
+		slog.Info("Job expired", "job", job.ID)
+	}
+
+	entries, err := os.ReadDir(s.root)
+	if err != nil {
+		slog.Warn("Could not read jobs folder", "error", err)
+		return
+	}
+	for _, e := range entries {
+		if !e.IsDir() || known[e.Name()] {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || now.Sub(info.ModTime()) <= s.ttl {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.root, e.Name())); err != nil {
+			slog.Warn("Could not delete expired job", "job", e.Name(), "error", err)
+			continue
+		}
+		slog.Info("Job expired", "job", e.Name())
+	}
+}
+
+func writeZip(w io.Writer, dir string) error {
+	zw := zip.NewWriter(w)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		hdr.Method = zip.Deflate
+		dst, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(dst, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// run waits for a free job slot and extracts the job's roll.
+func (s *server) run(job *serveJob) {
+	defer s.running.Done()
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-s.ctx.Done():
+		s.update(job, func(j *serveJob) { j.Status = jobCancelled })
+		return
+	}
+
+	s.update(job, func(j *serveJob) {
+		now := time.Now()
+		j.Status, j.Started = jobRunning, &now
+	})
+	logger := slog.Default().With("job", job.ID)
+	logger.Info("Extracting job", "file", job.input)
+
+	opts := s.opts
+	opts.Logger = logger
+	opts.OutputDir = filepath.Join(job.dir, "output")
+	opts.StateDir = filepath.Join(job.dir, "checkpoints")
+	opts.Memory = s.budget
+	opts.OnProgress = func(p pdfextract.Progress) {
+		s.update(job, func(j *serveJob) {
+			j.Pages, j.PagesDone, j.Photos = p.Pages, p.PagesDone, p.Photos
+		})
+	}
+	report := pdfextract.NewReport(opts.OnError)
+	report.Files = 1
+	report.Logger = logger
+	ex, err := pdfextract.New(opts)
+	if err != nil {
+		report.AddFailure(job.input, 0, err)
+	} else {
+		ex.Run(s.ctx, []string{job.input}, report)
+	}
+
+	if err := os.MkdirAll(opts.OutputDir, os.ModePerm); err == nil {
+		err = report.Write(filepath.Join(opts.OutputDir, "run_report.json"))
+		if err != nil {
+			logger.Warn("Could not write run report", "error", err)
+		}
+	}
+
+	failures := report.FailureList()
+	s.update(job, func(j *serveJob) {
+		now := time.Now()
+		j.Finished, j.Failures = &now, failures
+		j.Status = jobDone
+		// A failure without a page means the whole file could not be extracted
+		for _, f := range failures {
+			if f.Page == 0 {
+				j.Status = jobFailed
+			}
+		}
+		if report.Interrupted {
+			j.Status = jobCancelled
+		}
+	})
+	logger.Info("Job finished", "status", s.snapshot(job).Status, "failures", len(failures))
+}
+
+func (s *server) job(id string) *serveJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[id]
+}
+
+func (s *server) update(job *serveJob, fn func(*serveJob)) {
+	s.mu.Lock()
+	fn(job)
+	s.mu.Unlock()
+}
+
+func (s *server) snapshot(job *serveJob) serveJob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *job
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,175 @@
+package pdfextract
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// TextSource reads the text of a roll's pages. Pages are numbered from 1.
+type TextSource interface {
+	// PlainText is the text of a page, used to find voter IDs and records.
+	PlainText(pageNum int) (string, error)
+	// Layout is the text of a page with where every part was drawn, used
+	// to locate the voter IDs next to the photos.
+	Layout(pageNum int) (*TextLayout, error)
+	// Fonts are the base font names used on a page, to detect legacy fonts.
+	Fonts(pageNum int) ([]string, error)
+	// FontRuns is the plain text of a page cut where the font changes, so
+	// text set in a legacy font can be converted on its own.
+	FontRuns(pageNum int) ([]FontRun, error)
+}
+
+// FontRun is a piece of a page's text set in one font.
+type FontRun struct {
+	Text string
+	// Font is the base font name, "" for text the extractor inserted.
+	Font string
+}
+
+// appendRun adds text to runs, to the last run when it has the same font.
+func appendRun(runs []FontRun, text, font string) []FontRun {
+	if text == "" {
+		return runs
+	}
+	if n := len(runs); n > 0 && runs[n-1].Font == font {
+		runs[n-1].Text += text
+		return runs
+	}
+	return append(runs, FontRun{Text: text, Font: font})
+}
+
+// ImageSource reads the images drawn on a roll's pages.
+type ImageSource interface {
+	Images(pageNum int) (*PageImages, error)
+}
+
+// TextLayout is the text of a page with the position of every part of it.
+type TextLayout struct {
+	Text string
+	// Spans cover Text in order; each is a byte range of Text and where
+	// it was drawn.
+	Spans []TextSpan
+}
+
+// TextSpan is a piece of a page's text and its bounding box.
+type TextSpan struct {
+	Offset int
+	Len    int
+	Box    ManifestBox
+}
+
+// PageImages are the images of a page, in drawing order.
+type PageImages struct {
+	// MediaBox is the page area, in PDF points.
+	MediaBox ManifestBox
+	Images   []PageImage
+}
+
+// PageImage is an image drawn on a page.
+type PageImage struct {
+	// Box is where the image is drawn, in PDF points from the lower left.
+	Box   ManifestBox
+	Image image.Image
+	// Raw is the embedded stream when it is a standalone image file (JPEG
+	// or JPEG 2000), so it can be written unchanged.
+	Raw *RawImage
+}
+
+// RawImage is the still-encoded stream of an embedded image.
+type RawImage struct {
+	Encoding  string
+	Extension string
+	Data      []byte
+}
+
+// Backend libraries.
+const (
+	BackendUnipdf     = "unipdf"
+	BackendLedongthuc = "ledongthuc"
+	// BackendOffline reads images with this package's own decoder, which
+	// needs no unipdf license; see OfflineBackend.
+	BackendOffline = "offline"
+)
+
+// Backend says which library serves each part of the extraction: the plain
+// text (and fonts), the text layout and the images. Images are read by
+// unipdf or offline.
+type Backend struct {
+	Text   string
+	Layout string
+	Images string
+}
+
+// DefaultBackend reads plain text with ledongthuc/pdf, and the text layout
+// and images with unipdf.
+var DefaultBackend = Backend{Text: BackendLedongthuc, Layout: BackendUnipdf, Images: BackendUnipdf}
+
+// OfflineBackend needs neither a unipdf license nor network access: text and
+// layout come from ledongthuc/pdf, and the images are decoded by this
+// package from the page's JPEG and Flate image streams. Its output is laid
+// out like that of the other backends.
+var OfflineBackend = Backend{Text: BackendLedongthuc, Layout: BackendLedongthuc, Images: BackendOffline}
+
+// ParseBackend parses "unipdf", "ledongthuc" (text and layout from
+// ledongthuc/pdf, images from unipdf), "offline" (OfflineBackend) or a mix
+// such as
+// "text=unipdf,layout=ledongthuc,images=unipdf"; parts left out of a mix
+// keep their default.
+func ParseBackend(s string) (Backend, error) {
+	switch s {
+	case "":
+		return DefaultBackend, nil
+	case BackendUnipdf:
+		return Backend{Text: BackendUnipdf, Layout: BackendUnipdf, Images: BackendUnipdf}, nil
+	case BackendLedongthuc:
+		return Backend{Text: BackendLedongthuc, Layout: BackendLedongthuc, Images: BackendUnipdf}, nil
+	case BackendOffline:
+		return OfflineBackend, nil
+	}
+
+	b := DefaultBackend
+	for _, part := range strings.Split(s, ",") {
+		role, lib, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return Backend{}, fmt.Errorf("invalid backend %q (want unipdf, ledongthuc, offline or role=library,...)", s)
+		}
+		switch role {
+		case "text":
+			b.Text = lib
+		case "layout":
+			b.Layout = lib
+		case "images":
+			b.Images = lib
+		default:
+			return Backend{}, fmt.Errorf("unknown backend role %q (want text, layout or images)", role)
+		}
+	}
+	return b, b.validate()
+}
+
+func (b Backend) validate() error {
+	for _, lib := range []string{b.Text, b.Layout} {
+		if lib != BackendUnipdf && lib != BackendLedongthuc {
+			return fmt.Errorf("unknown text backend %q (want unipdf or ledongthuc)", lib)
+		}
+	}
+	if b.Images != BackendUnipdf && b.Images != BackendOffline {
+		return fmt.Errorf("unknown image backend %q (want unipdf or offline)", b.Images)
+	}
+	return nil
+}
+
+func (b Backend) String() string {
+	return fmt.Sprintf("text=%s,layout=%s,images=%s", b.Text, b.Layout, b.Images)
+}
+
+func (b Backend) uses(lib string) bool {
+	return b.Text == lib || b.Layout == lib || b.Images == lib
+}
+
+// NeedsLicense reports whether any part of the backend uses unipdf's
+// licensed extraction.
+func (b Backend) NeedsLicense() bool {
+	return b.uses(BackendUnipdf)
+}
@@ -0,0 +1,181 @@
+package pdfextract
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// checkpointLine is one line of a checkpoint file: either a completed page
+// or, as the last line, the marker that the whole file is done.
+type checkpointLine struct {
+	Source  string          `json:"source"`
+	Page    int             `json:"page,omitempty"`
+	Records []VoterRecord   `json:"records,omitempty"`
+	Photos  []ManifestEntry `json:"photos,omitempty"`
+	Done    bool            `json:"done,omitempty"`
+}
+
+// checkpoint remembers which pages of an input file were written. It is keyed
+// by the SHA-256 of the file, so an edited file is never mistaken for a done
+// one, together with its path, the output folder and the settings that shape
+// the output. Identical files at different paths each have their own.
+type checkpoint struct {
+	source string
+	hash   string
+	file   *os.File
+	pages  map[int]*pageResult
+	done   bool
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Loads the checkpoint of an input file from dir, starting a fresh one when
+// there is none or force is set. Without a dir nothing is remembered.
+func openCheckpoint(dir, source, outDir string, opts Options, force bool) (*checkpoint, error) {
+	if dir == "" {
+		return &checkpoint{source: source, pages: make(map[int]*pageResult)}, nil
+	}
+	hash, err := hashFile(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return nil, err
+	}
+	absOut, err := filepath.Abs(outDir)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := json.Marshal(struct {
+		Source      string
+		OutputDir   string
+		Records     bool
+		Template    *Template
+		Format      Format
+		JPEGQuality int
+		PhotoHash   bool   `json:",omitempty"`
+		Backend     string `json:",omitempty"`
+	}{absSource, absOut, opts.Records, opts.Template, opts.Format, opts.JPEGQuality, opts.PhotoHash, backendKey(opts.Backend)})
+	if err != nil {
+		return nil, err
+	}
+	runKey := fmt.Sprintf("%x", sha256.Sum256(settings))[:12]
+	path := filepath.Join(dir, hash+"-"+runKey+".jsonl")
+
+	c := &checkpoint{
+		source: source,
+		hash:   hash,
+		pages:  make(map[int]*pageResult),
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if force {
+		flags |= os.O_TRUNC
+	} else if err := c.load(path); err != nil {
+		return nil, err
+	}
+
+	if c.file, err = os.OpenFile(path, flags, 0644); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *checkpoint) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var line checkpointLine
+		// A crash can leave the last line half written; it is simply redone.
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		if line.Done {
+			c.done = true
+			continue
+		}
+		photos := make([]pagePhoto, 0, len(line.Photos))
+		for _, e := range line.Photos {
+			photos = append(photos, pagePhoto{Entry: e})
+		}
+		c.pages[line.Page] = &pageResult{
+			PageNum:  line.Page,
+			Photos:   photos,
+			Records:  line.Records,
+			Restored: true,
+		}
+	}
+	return scanner.Err()
+}
+
+// Checkpoints of runs with the default backend keep the key they had
+// before backends could be chosen.
+func backendKey(b Backend) string {
+	if b == DefaultBackend || b == (Backend{}) {
+		return ""
+	}
+	return b.String()
+}
+
+func (c *checkpoint) append(line checkpointLine) error {
+	if c.file == nil {
+		return nil
+	}
+	line.Source = c.source
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	_, err = c.file.Write(append(data, '\n'))
+	return err
+}
+
+// recordPage notes that all output of the page has been written.
+func (c *checkpoint) recordPage(r *pageResult) error {
+	entries := make([]ManifestEntry, 0, len(r.Photos))
+	for _, p := range r.Photos {
+		entries = append(entries, p.Entry)
+	}
+	return c.append(checkpointLine{Page: r.PageNum, Records: r.Records, Photos: entries})
+}
+
+func (c *checkpoint) markDone() error {
+	return c.append(checkpointLine{Done: true})
+}
+
+func (c *checkpoint) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	return c.file.Close()
+}
@@ -0,0 +1,289 @@
+package pdfextract
+
+import (
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// Occurrence is one place a voter ID was found: a photo paired with the ID,
+// a parsed voter record, or both when they are on the same page.
+type Occurrence struct {
+	VoterID string `json:"voter_id"`
+	File    string `json:"file"`
+	Page    int    `json:"page"`
+	// ImageIndex and BBox locate the photo on the page; ImageIndex is -1
+	// when only the record was found.
+	ImageIndex int          `json:"image_index"`
+	BBox       *ManifestBox `json:"bbox,omitempty"`
+	PhotoFile  string       `json:"photo_file,omitempty"`
+	PhotoPath  string       `json:"photo_path,omitempty"`
+	PhotoHash  string       `json:"photo_hash,omitempty"`
+	// Record is the parsed voter record, when records are extracted.
+	Record *VoterRecord  `json:"record,omitempty"`
+	Roll   *RollMetadata `json:"roll,omitempty"`
+}
+
+type occurrenceKey struct {
+	file string
+	page int
+	id   string
+}
+
+// VoterIndex collects every occurrence of every voter ID in a run. Pass
+// AddPhoto and AddRecord as Options.OnPhoto and Options.OnRecord; records
+// are merged into the photo occurrence of the same ID on the same page.
+type VoterIndex struct {
+	mu          sync.Mutex
+	occurrences []*Occurrence
+	byPage      map[occurrenceKey][]*Occurrence
+}
+
+// NewVoterIndex returns an empty index.
+func NewVoterIndex() *VoterIndex {
+	return &VoterIndex{byPage: make(map[occurrenceKey][]*Occurrence)}
+}
+
+// AddPhoto indexes the ID of an extracted photo.
+func (x *VoterIndex) AddPhoto(p ExtractedPhoto) {
+	if p.VoterID == "" {
+		return
+	}
+	box := p.BBox
+	occ := &Occurrence{
+		VoterID:    p.VoterID,
+		File:       p.SourceFile,
+		Page:       p.Page,
+		ImageIndex: p.ImageIndex,
+		BBox:       &box,
+		PhotoFile:  p.OutputFile,
+		PhotoPath:  p.Path,
+		PhotoHash:  p.PhotoHash,
+		Roll:       p.Roll,
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	key := occurrenceKey{occ.File, occ.Page, occ.VoterID}
+	x.byPage[key] = append(x.byPage[key], occ)
+	x.occurrences = append(x.occurrences, occ)
+}
+
+// AddRecord indexes the ID of a parsed voter record. Records whose voter
+// ID could not be read are left out, so they are not all taken for one voter.
+func (x *VoterIndex) AddRecord(r VoterRecord) {
+	if r.VoterID == "" {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	key := occurrenceKey{r.SourceFile, r.Page, r.VoterID}
+	for _, occ := range x.byPage[key] {
+		if occ.Record == nil {
+			occ.Record = &r
+			return
+		}
+	}
+	occ := &Occurrence{
+		VoterID:    r.VoterID,
+		File:       r.SourceFile,
+		Page:       r.Page,
+		ImageIndex: -1,
+		PhotoFile:  r.PhotoFile,
+		Record:     &r,
+		Roll:       r.Roll,
+	}
+	x.byPage[key] = append(x.byPage[key], occ)
+	x.occurrences = append(x.occurrences, occ)
+}
+
+// Occurrences returns everything indexed so far, by file, page and position.
+func (x *VoterIndex) Occurrences() []Occurrence {
+	x.mu.Lock()
+	out := make([]Occurrence, len(x.occurrences))
+	for i, occ := range x.occurrences {
+		out[i] = *occ
+	}
+	x.mu.Unlock()
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Page != b.Page {
+			return a.Page < b.Page
+		}
+		return a.ImageIndex < b.ImageIndex
+	})
+	return out
+}
+
+// DuplicateReport lists voter IDs found more than once and photos that
+// look the same under different IDs.
+type DuplicateReport struct {
+	Occurrences int             `json:"occurrences"`
+	IDs         []DuplicateID   `json:"duplicate_ids"`
+	Photos      []SimilarPhotos `json:"similar_photos"`
+	// MaxDistance is the largest photo hash distance counted as the same photo.
+	MaxDistance int `json:"max_distance"`
+}
+
+// DuplicateID is a voter ID with all the places it was found.
+type DuplicateID struct {
+	VoterID     string       `json:"voter_id"`
+	Files       int          `json:"files"`
+	Occurrences []Occurrence `json:"occurrences"`
+}
+
+// SimilarPhotos is a group of photos under different IDs, each at most
+// MaxDistance bits from another photo of the group.
+type SimilarPhotos struct {
+	VoterIDs    []string     `json:"voter_ids"`
+	Distance    int          `json:"distance"`
+	Occurrences []Occurrence `json:"occurrences"`
+}
+
+// Duplicates finds the IDs that occur more than once, and photos with
+// different IDs at most maxDistance bits apart. Photos are only compared
+// when they were hashed, see Options.PhotoHash.
+func (x *VoterIndex) Duplicates(maxDistance int) DuplicateReport {
+	occurrences := x.Occurrences()
+	report := DuplicateReport{
+		Occurrences: len(occurrences),
+		IDs:         []DuplicateID{},
+		Photos:      []SimilarPhotos{},
+		MaxDistance: maxDistance,
+	}
+
+	byID := make(map[string][]Occurrence)
+	var ids []string
+	for _, occ := range occurrences {
+		if _, ok := byID[occ.VoterID]; !ok {
+			ids = append(ids, occ.VoterID)
+		}
+		byID[occ.VoterID] = append(byID[occ.VoterID], occ)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		occs := byID[id]
+		if len(occs) < 2 {
+			continue
+		}
+		files := make(map[string]bool)
+		for _, occ := range occs {
+			files[occ.File] = true
+		}
+		report.IDs = append(report.IDs, DuplicateID{VoterID: id, Files: len(files), Occurrences: occs})
+	}
+
+	report.Photos = similarPhotos(occurrences, maxDistance)
+	return report
+}
+
+// Groups hashed photos at most maxDistance bits apart, keeping the groups
+// with more than one ID. The hashes are cut into maxDistance+1 bands; two
+// hashes that close agree on at least one whole band, so only photos sharing
+// a band value are compared.
+func similarPhotos(occurrences []Occurrence, maxDistance int) []SimilarPhotos {
+	groups := []SimilarPhotos{}
+	if maxDistance < 0 {
+		return groups
+	}
+	bands := maxDistance + 1
+	if bands > 64 {
+		bands = 64
+	}
+
+	var photos []int
+	hashes := make(map[int]uint64)
+	for i, occ := range occurrences {
+		if occ.PhotoHash == "" {
+			continue
+		}
+		h, err := parsePhotoHash(occ.PhotoHash)
+		if err != nil {
+			continue
+		}
+		photos = append(photos, i)
+		hashes[i] = h
+	}
+
+	// Union-find over the photos, with the largest linking distance per group
+	parent := make(map[int]int, len(photos))
+	for _, i := range photos {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	distance := make(map[int]int)
+
+	for band := 0; band < bands; band++ {
+		from, to := band*64/bands, (band+1)*64/bands
+		mask := ^uint64(0)
+		if to-from < 64 {
+			mask = (uint64(1)<<(to-from) - 1) << from
+		}
+		buckets := make(map[uint64][]int)
+		for _, i := range photos {
+			key := hashes[i] & mask
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, bucket := range buckets {
+			for x := 0; x < len(bucket); x++ {
+				for y := x + 1; y < len(bucket); y++ {
+					a, b := bucket[x], bucket[y]
+					d := bits.OnesCount64(hashes[a] ^ hashes[b])
+					if d > maxDistance {
+						continue
+					}
+					ra, rb := find(a), find(b)
+					if ra != rb {
+						parent[rb] = ra
+						distance[ra] = max(distance[ra], distance[rb])
+					}
+					distance[ra] = max(distance[ra], d)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]int)
+	var roots []int
+	for _, i := range photos {
+		r := find(i)
+		if _, ok := members[r]; !ok {
+			roots = append(roots, r)
+		}
+		members[r] = append(members[r], i)
+	}
+	for _, r := range roots {
+		group := SimilarPhotos{Distance: distance[r]}
+		ids := make(map[string]bool)
+		for _, i := range members[r] {
+			occ := occurrences[i]
+			if !ids[occ.VoterID] {
+				ids[occ.VoterID] = true
+				group.VoterIDs = append(group.VoterIDs, occ.VoterID)
+			}
+			group.Occurrences = append(group.Occurrences, occ)
+		}
+		if len(group.VoterIDs) < 2 {
+			continue
+		}
+		sort.Strings(group.VoterIDs)
+		groups = append(groups, group)
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Distance != groups[j].Distance {
+			return groups[i].Distance < groups[j].Distance
+		}
+		return groups[i].VoterIDs[0] < groups[j].VoterIDs[0]
+	})
+	return groups
+}
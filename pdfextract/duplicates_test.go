@@ -0,0 +1,149 @@
+package pdfextract
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"reflect"
+	"testing"
+)
+
+// testPhoto draws a 90x80 grey photo with some structure, optionally mirrored.
+func testPhoto(mirrored bool) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 90, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 90; x++ {
+			px := x
+			if mirrored {
+				px = 89 - x
+			}
+			v := (px*px/8 + 3*y + (px/10)*(y/10)*17) % 251
+			img.SetGray(x, y, color.Gray{Y: uint8(v)})
+		}
+	}
+	return img
+}
+
+// nearCopy is img a little brighter, re-encoded as a low quality JPEG.
+func nearCopy(t *testing.T, img *image.Gray) image.Image {
+	t.Helper()
+	bright := image.NewGray(img.Bounds())
+	for i, v := range img.Pix {
+		bright.Pix[i] = uint8(min(int(v)+4, 255))
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, bright, &jpeg.Options{Quality: 50}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestPhotoHash(t *testing.T) {
+	photo := testPhoto(false)
+	h := formatPhotoHash(photoHash(photo))
+	near := formatPhotoHash(photoHash(nearCopy(t, photo)))
+	other := formatPhotoHash(photoHash(testPhoto(true)))
+
+	if d, err := PhotoHashDistance(h, near); err != nil || d > 6 {
+		t.Errorf("near copy: distance %d (%v), want at most 6", d, err)
+	}
+	if d, err := PhotoHashDistance(h, other); err != nil || d < 20 {
+		t.Errorf("other photo: distance %d (%v), want at least 20", d, err)
+	}
+	if _, err := PhotoHashDistance(h, "not a hash"); err == nil {
+		t.Error("PhotoHashDistance accepted an invalid hash")
+	}
+
+	// Photos smaller than the 9x8 grid still get a hash
+	tiny := image.NewGray(image.Rect(0, 0, 3, 2))
+	tiny.Pix = []uint8{10, 200, 30, 250, 0, 90}
+	if got, err := parsePhotoHash(formatPhotoHash(photoHash(tiny))); err != nil || got != photoHash(tiny) {
+		t.Errorf("tiny photo hash does not round trip: %v", err)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	photo := testPhoto(false)
+	hash := func(img image.Image) string { return formatPhotoHash(photoHash(img)) }
+	add := func(x *VoterIndex, id, file string, page, index int, photoHash string) {
+		x.AddPhoto(ExtractedPhoto{ManifestEntry: ManifestEntry{
+			VoterID: id, SourceFile: file, Page: page, ImageIndex: index, PhotoHash: photoHash,
+		}})
+	}
+
+	x := NewVoterIndex()
+	add(x, "50000001", "a.pdf", 2, 0, hash(photo))
+	// The same photo again under another ID, and a different voter
+	add(x, "50000002", "a.pdf", 2, 1, hash(nearCopy(t, photo)))
+	add(x, "50000003", "a.pdf", 2, 2, hash(testPhoto(true)))
+	// The first voter listed again in another roll, without a hashed photo
+	add(x, "50000001", "b.pdf", 5, 3, "")
+	x.AddRecord(VoterRecord{VoterID: "50000001", SourceFile: "b.pdf", Page: 5, Name: "राम"})
+	x.AddRecord(VoterRecord{SourceFile: "b.pdf", Page: 5, Name: "unread"})
+
+	report := x.Duplicates(6)
+
+	if report.Occurrences != 4 {
+		t.Errorf("%d occurrences, want 4", report.Occurrences)
+	}
+	if len(report.IDs) != 1 || report.IDs[0].VoterID != "50000001" || report.IDs[0].Files != 2 {
+		t.Fatalf("duplicate IDs = %+v, want 50000001 in 2 files", report.IDs)
+	}
+	if rec := report.IDs[0].Occurrences[1].Record; rec == nil || rec.Name != "राम" {
+		t.Errorf("record not merged into the photo occurrence of its page: %+v", report.IDs[0].Occurrences[1])
+	}
+	if len(report.Photos) != 1 {
+		t.Fatalf("similar photos = %+v, want one group", report.Photos)
+	}
+	if got := report.Photos[0].VoterIDs; !reflect.DeepEqual(got, []string{"50000001", "50000002"}) {
+		t.Errorf("similar photo IDs = %v", got)
+	}
+	if d := report.Photos[0].Distance; d > 6 {
+		t.Errorf("group distance %d, want at most 6", d)
+	}
+}
+
+func TestSimilarPhotos(t *testing.T) {
+	occ := func(id string, h uint64) Occurrence {
+		return Occurrence{VoterID: id, PhotoHash: formatPhotoHash(h)}
+	}
+	const h = 0x14322c535ca7b8cf
+	occurrences := []Occurrence{
+		occ("50000001", h),
+		occ("50000002", h^0b111),    // 3 bits from the first
+		occ("50000003", h^0b111<<8), // 3 bits from the first, 6 from the second
+		// Far from all; one voter's photos alone are no group
+		occ("50000004", h^0xffffffff<<32),
+		occ("50000004", h^0xffffffff<<32^1),
+		{VoterID: "50000005"}, // no hashed photo
+	}
+	tests := []struct {
+		maxDistance int
+		want        [][]string
+		distances   []int
+	}{
+		{maxDistance: 2, want: [][]string{}},
+		// The second and third are linked through the first; the group's
+		// distance is its largest link
+		{maxDistance: 3, want: [][]string{{"50000001", "50000002", "50000003"}}, distances: []int{3}},
+		{maxDistance: 6, want: [][]string{{"50000001", "50000002", "50000003"}}, distances: []int{6}},
+		{maxDistance: -1, want: [][]string{}},
+	}
+	for _, tt := range tests {
+		groups := similarPhotos(occurrences, tt.maxDistance)
+		got := [][]string{}
+		var distances []int
+		for _, g := range groups {
+			got = append(got, g.VoterIDs)
+			distances = append(distances, g.Distance)
+		}
+		if !reflect.DeepEqual(got, tt.want) || !reflect.DeepEqual(distances, tt.distances) {
+			t.Errorf("maxDistance %d: groups %v at %v, want %v at %v", tt.maxDistance, got, distances, tt.want, tt.distances)
+		}
+	}
+}
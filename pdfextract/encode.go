@@ -0,0 +1,81 @@
+package pdfextract
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+// Format is how photos are written to disk.
+type Format string
+
+const (
+	// JPEG, passing DCT streams through unchanged and encoding everything else.
+	FormatJPEG Format = "jpeg"
+	// Lossless PNG of the decoded pixels.
+	FormatPNG Format = "png"
+	// Lossless WebP of the decoded pixels.
+	FormatWebP Format = "webp"
+	// The embedded stream as-is where it is a standalone image format, else PNG.
+	FormatOriginal Format = "original"
+)
+
+// ParseFormat parses jpeg, png, webp or original.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatJPEG, FormatPNG, FormatWebP, FormatOriginal:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown output format %q (want jpeg, png, webp or original)", s)
+}
+
+// encodedPhoto is a photo ready to be written, and what the manifest says about it.
+type encodedPhoto struct {
+	Data        []byte
+	Encoding    string
+	Extension   string
+	Passthrough bool
+	Width       int
+	Height      int
+}
+
+// Encodes a page image in the requested format. The original stream is
+// written unchanged whenever that keeps the requested format, so the pixels
+// stay exactly as they are in the PDF.
+func encodePhoto(img PageImage, format Format, quality int) (*encodedPhoto, error) {
+	bounds := img.Image.Bounds()
+	if raw := img.Raw; raw != nil &&
+		(format == FormatOriginal || format == FormatJPEG && raw.Encoding == "jpeg") {
+		return &encodedPhoto{
+			Data:        raw.Data,
+			Encoding:    raw.Encoding,
+			Extension:   raw.Extension,
+			Passthrough: true,
+			Width:       bounds.Dx(),
+			Height:      bounds.Dy(),
+		}, nil
+	}
+
+	out := &encodedPhoto{Width: bounds.Dx(), Height: bounds.Dy()}
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatJPEG:
+		out.Encoding, out.Extension = "jpeg", ".jpg"
+		err = jpeg.Encode(&buf, img.Image, &jpeg.Options{Quality: quality})
+	case FormatWebP:
+		out.Encoding, out.Extension = "webp", ".webp"
+		err = nativewebp.Encode(&buf, img.Image, nil)
+	default:
+		out.Encoding, out.Extension = "png", ".png"
+		err = png.Encode(&buf, img.Image)
+	}
+	if err != nil {
+		return nil, WithCategory(CategoryEncode, err)
+	}
+	out.Data = buf.Bytes()
+	return out, nil
+}
@@ -0,0 +1,360 @@
+package pdfextract
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// pagePhoto is an encoded voter photo waiting to be written out.
+type pagePhoto struct {
+	Entry ManifestEntry
+	Data  []byte
+}
+
+// pageResult is everything extracted from one page.
+type pageResult struct {
+	PageNum int
+	Photos  []pagePhoto
+	Records []VoterRecord
+	Elapsed time.Duration
+	// Restored pages come from a checkpoint; their photos are already on disk.
+	Restored bool
+	// Overlay is the debug overlay PNG, when Options.DebugOverlay is set.
+	Overlay []byte
+}
+
+// Extracts the photos of one page and names them after the voter ID printed
+// in the same record cell.
+func extractPage(ctx context.Context, doc *docSession, pageNum int, opts Options) (*pageResult, error) {
+	inputPath := doc.path
+	tpl := opts.Template
+	logger := doc.log.With("page", pageNum)
+	logger.Debug("Extracting page")
+	pageStart := time.Now()
+
+	text, err := doc.pageText(pageNum, tpl.LegacyFont)
+	if err != nil {
+		logger.Warn("Could not extract text", "error", err)
+		return nil, WithCategory(CategoryText, fmt.Errorf("could not extract text from page %d of file %v: %v", pageNum, inputPath, err))
+	}
+	ids := tpl.ids.forPage()
+	voterIDs := extractVoterIDs(text, ids, logger)
+	logger.Debug("Found candidate IDs", "count", len(voterIDs), "voter_ids", voterIDs)
+
+	pageImages, err := doc.images.Images(pageNum)
+	if err != nil {
+		return nil, WithCategory(CategoryImage, err)
+	}
+
+	imgCount := len(pageImages.Images)
+	logger.Debug("Found images", "count", imgCount)
+
+	result := &pageResult{PageNum: pageNum}
+	if opts.Records {
+		result.Records = parseVoterRecords(text, pageNum, ids, logger)
+	}
+
+	if imgCount == 0 {
+		result.Elapsed = time.Since(pageStart)
+		return result, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	layout, err := doc.layout.Layout(pageNum)
+	if err != nil {
+		return nil, WithCategory(CategoryText, err)
+	}
+	idMarks, missing := locateIDMarks(layout, voterIDs, tpl.ids.pattern)
+	if len(missing) > 0 {
+		logger.Warn("Could not locate IDs in text marks", "voter_ids", missing)
+	}
+
+	photos, headers := tpl.splitHeaderImages(photoMarksFromImages(pageImages.Images))
+	for _, h := range headers {
+		logger.Debug("Skipping header image", "image_index", h.Index)
+	}
+
+	pairs := pairPhotosWithIDs(photos, idMarks, tpl.recordCell)
+	for _, p := range pairs.UnpairedPhotos {
+		logger.Warn("Image has no ID in its record cell, not saved", "image_index", p.Index, "x", p.Box.X, "y", p.Box.Y)
+	}
+	for _, id := range pairs.UnpairedIDs {
+		logger.Warn("ID has no photo in its record cell", "voter_id", id.ID, "x", id.Box.X, "y", id.Box.Y)
+	}
+
+	if opts.DebugOverlay {
+		overlay, err := renderOverlay(doc, pageNum, pageImages.MediaBox, pairs, headers, missing)
+		if err != nil {
+			logger.Warn("Could not draw the debug overlay", "error", err)
+		}
+		result.Overlay = overlay
+	}
+
+	for _, pair := range pairs.Pairs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		photo, err := encodePhoto(pair.Photo.Image, opts.Format, opts.JPEGQuality)
+		if err != nil {
+			return nil, err
+		}
+		hash := ""
+		if opts.PhotoHash {
+			hash = formatPhotoHash(photoHash(pair.Photo.Image.Image))
+		}
+
+		filename := pair.ID.ID + photo.Extension
+		photoBox := pair.Photo.Box
+		result.Photos = append(result.Photos, pagePhoto{
+			Entry: ManifestEntry{
+				SourceFile:  inputPath,
+				Page:        pageNum,
+				ImageIndex:  pair.Photo.Index,
+				BBox:        ManifestBox{X: photoBox.X, Y: photoBox.Y, Width: photoBox.W, Height: photoBox.H},
+				VoterID:     pair.ID.ID,
+				OutputFile:  filename,
+				PixelWidth:  photo.Width,
+				PixelHeight: photo.Height,
+				Encoding:    photo.Encoding,
+				Passthrough: photo.Passthrough,
+				SHA256:      fmt.Sprintf("%x", sha256.Sum256(photo.Data)),
+				PhotoHash:   hash,
+			},
+			Data: photo.Data,
+		})
+	}
+
+	result.Elapsed = time.Since(pageStart)
+	logger.Debug("Extracted page", "photos", len(result.Photos), "elapsed", result.Elapsed)
+	return result, nil
+}
+
+// fileOutput writes the page results of one input file, in page order.
+type fileOutput struct {
+	inputPath string
+	pdfDir    string
+	prefix    string // see fileNamePrefix
+	meta      RollMetadata
+	opts      Options
+	report    *Report
+	outputs   *outputRegistry
+	log       *slog.Logger
+	start     time.Time
+
+	records        []VoterRecord
+	manifest       []ManifestEntry
+	totalExtracted int
+	pagesDone      int
+	pageTime       time.Duration
+}
+
+// pdfOutputDir is the folder the photos of one input file are written to.
+func pdfOutputDir(inputPath string, meta RollMetadata, opts Options) string {
+	if opts.OutputDir == "" {
+		return ""
+	}
+	layout := opts.OutputLayout
+	if layout == "" {
+		layout = DefaultOutputLayout
+	}
+	return filepath.Join(opts.OutputDir, meta.expandLayout(layout, inputPath))
+}
+
+func newFileOutput(inputPath string, meta RollMetadata, b *batch) (*fileOutput, error) {
+	opts := b.opts
+	pdfDir := pdfOutputDir(inputPath, meta, opts)
+	if pdfDir != "" {
+		if err := os.MkdirAll(pdfDir, os.ModePerm); err != nil {
+			return nil, WithCategory(CategoryWrite, err)
+		}
+	}
+	return &fileOutput{
+		inputPath: inputPath,
+		pdfDir:    pdfDir,
+		prefix:    fileNamePrefix(opts.OutputLayout, inputPath),
+		meta:      meta,
+		opts:      opts,
+		report:    b.report,
+		outputs:   b.outputs,
+		log:       opts.Logger.With("file", inputPath),
+		start:     time.Now(),
+	}, nil
+}
+
+func (o *fileOutput) writePage(r *pageResult) error {
+	if len(r.Overlay) > 0 && o.pdfDir != "" {
+		if err := o.writeOverlay(r); err != nil {
+			return WithCategory(CategoryWrite, err)
+		}
+	}
+	kept := make([]pagePhoto, 0, len(r.Photos))
+	for _, photo := range r.Photos {
+		if o.pdfDir != "" {
+			if r.Restored {
+				// Written by an earlier run under the name it has now
+				o.outputs.set(filepath.Join(o.pdfDir, photo.Entry.OutputFile), photoSourceOf(photo.Entry))
+			} else {
+				name, err := o.claimOutput(photo.Entry)
+				if err != nil {
+					return err
+				}
+				if name == "" {
+					continue
+				}
+				photo.Entry.OutputFile = name
+
+				fullPath := filepath.Join(o.pdfDir, name)
+				if err := WriteFileAtomic(fullPath, photo.Data); err != nil {
+					return WithCategory(CategoryWrite, err)
+				}
+				o.log.Debug("Saved photo", "page", r.PageNum, "image_index", photo.Entry.ImageIndex, "voter_id", photo.Entry.VoterID, "output_file", name)
+			}
+		}
+		kept = append(kept, photo)
+		entry := photo.Entry
+		entry.Roll = &o.meta
+		o.manifest = append(o.manifest, entry)
+		o.totalExtracted++
+		if o.opts.OnPhoto != nil {
+			path := ""
+			if o.pdfDir != "" {
+				path = filepath.Join(o.pdfDir, entry.OutputFile)
+			}
+			o.opts.OnPhoto(ExtractedPhoto{ManifestEntry: entry, Data: photo.Data, Path: path})
+		}
+	}
+	// The checkpoint records the photos as they were actually written
+	r.Photos = kept
+	if !r.Restored {
+		// Records name their photo as written: renamed by a collision, or
+		// none when it was skipped
+		written := make(map[string]string, len(kept))
+		for _, photo := range kept {
+			written[photo.Entry.VoterID] = photo.Entry.OutputFile
+		}
+		for i := range r.Records {
+			r.Records[i].PhotoFile = written[r.Records[i].VoterID]
+		}
+	}
+	for _, rec := range r.Records {
+		rec.SourceFile = o.inputPath
+		rec.Roll = &o.meta
+		o.records = append(o.records, rec)
+		if o.opts.OnRecord != nil {
+			o.opts.OnRecord(rec)
+		}
+	}
+	o.pagesDone++
+	o.pageTime += r.Elapsed
+	return nil
+}
+
+// Debug overlays go to debug/page-NNN.png in the file's output folder.
+func (o *fileOutput) writeOverlay(r *pageResult) error {
+	dir := filepath.Join(o.pdfDir, "debug")
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(dir, fmt.Sprintf("page-%03d.png", r.PageNum)), r.Overlay)
+}
+
+func (o *fileOutput) finish() error {
+	if o.pdfDir == "" {
+		return nil
+	}
+	if err := writeManifest(o.pdfDir, o.prefix, o.manifest); err != nil {
+		return WithCategory(CategoryWrite, err)
+	}
+	if o.opts.Records {
+		if err := writeVoterRecords(o.pdfDir, o.prefix, o.records); err != nil {
+			return WithCategory(CategoryWrite, err)
+		}
+		o.log.Info("Wrote voter records", "records", len(o.records))
+	}
+	attrs := []any{"photos", o.totalExtracted, "output_dir", o.pdfDir, "pages", o.pagesDone, "elapsed", time.Since(o.start)}
+	if o.pagesDone > 0 {
+		attrs = append(attrs, "page_average", o.pageTime/time.Duration(o.pagesDone))
+	}
+	o.log.Info("Completed file", attrs...)
+	return nil
+}
+
+func extractVoterIDs(extractedText string, ids idMatcher, logger *slog.Logger) []string {
+	// Clean the text first
+	cleaned := strings.ReplaceAll(extractedText, "\uFFFD", "")
+
+	// Remove any garbage patterns like "12345.-" -> "12345"
+	garbageRegex := regexp.MustCompile(`(\d+)[.\-]+`)
+	cleaned = garbageRegex.ReplaceAllString(cleaned, "$1")
+
+	// Split text into lines for better analysis
+	lines := strings.Split(cleaned, "\n")
+
+	var candidates []string
+
+	// Pattern to match serial numbers (क.सं. followed by digits)
+	serialNumberPattern := regexp.MustCompile(`क\.सं\.\s*(\d+)`)
+
+	// Track serial numbers to exclude them
+	serialNumbers := make(map[string]bool)
+
+	// First pass: identify all serial numbers (क.सं.)
+	for _, line := range lines {
+		matches := serialNumberPattern.FindAllStringSubmatch(line, -1)
+		for _, match := range matches {
+			if len(match) > 1 {
+				serialNumbers[match[1]] = true
+			}
+		}
+	}
+
+	logger.Debug("Serial numbers to exclude", "serial_numbers", serialNumbers)
+
+	// Second pass: extract all numbers, excluding serial numbers
+	for _, line := range lines {
+		// Skip lines that contain "क.सं." entirely (these are just serial number lines)
+		if strings.Contains(line, "क.सं.") || strings.Contains(line, "क.स.") {
+			continue
+		}
+
+		matches := ids.pattern.FindAllStringSubmatchIndex(line, -1)
+		for _, match := range matches {
+			start, end := idSpan(match)
+			id := line[start:end]
+
+			// Skip if it's a known serial number
+			if serialNumbers[id] {
+				logger.Debug("Skipping serial number", "serial_number", id)
+				continue
+			}
+
+			// Skip candidates rejected by the template's ID rules
+			if !ids.accept(id, logger) {
+				continue
+			}
+
+			candidates = append(candidates, id)
+		}
+	}
+
+	// Remove duplicates while preserving order
+	seen := make(map[string]bool)
+	uniqueIDs := []string{}
+	for _, id := range candidates {
+		if !seen[id] {
+			seen[id] = true
+			uniqueIDs = append(uniqueIDs, id)
+		}
+	}
+
+	return uniqueIDs
+}
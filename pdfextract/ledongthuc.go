@@ -0,0 +1,175 @@
+package pdfextract
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// ledongthucSource reads text with ledongthuc/pdf, which needs no license.
+type ledongthucSource struct {
+	reader *pdf.Reader
+}
+
+func newLedongthucSource(f *os.File, size int64) (*ledongthucSource, int, error) {
+	reader, err := pdf.NewReader(f, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &ledongthucSource{reader: reader}, reader.NumPage(), nil
+}
+
+func (s *ledongthucSource) page(pageNum int) (pdf.Page, error) {
+	totalPages := s.reader.NumPage()
+	if pageNum > totalPages {
+		return pdf.Page{}, fmt.Errorf("page %d out of range (total: %d)", pageNum, totalPages)
+	}
+	return s.reader.Page(pageNum), nil
+}
+
+func (s *ledongthucSource) PlainText(pageNum int) (string, error) {
+	p, err := s.page(pageNum)
+	if err != nil {
+		return "", err
+	}
+	if p.V.IsNull() {
+		return "", nil
+	}
+	return p.GetPlainText(nil)
+}
+
+// Layout joins the glyphs of a page in drawing order, with a space where
+// they are apart and a line break where the line changes.
+func (s *ledongthucSource) Layout(pageNum int) (layout *TextLayout, err error) {
+	p, err := s.page(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	if p.V.IsNull() {
+		return &TextLayout{}, nil
+	}
+	// The content parser panics on malformed streams
+	defer func() {
+		if r := recover(); r != nil {
+			layout, err = nil, fmt.Errorf("could not read the text layout of page %d: %v", pageNum, r)
+		}
+	}()
+
+	var b strings.Builder
+	layout = &TextLayout{}
+	glyphs := p.Content().Text
+	var prev *pdf.Text
+	for i := range glyphs {
+		t := &glyphs[i]
+		// Fonts without a width table (the standard 14 fonts) leave every
+		// glyph at the start of its run with no width, so estimate both
+		if t.W == 0 {
+			t.W = t.FontSize * 0.55 * float64(utf8.RuneCountInString(t.S))
+			if prev != nil && t.Y == prev.Y && t.X <= prev.X {
+				t.X = prev.X + prev.W
+			}
+		}
+		if prev != nil {
+			switch {
+			case math.Abs(t.Y-prev.Y) > prev.FontSize/2:
+				b.WriteByte('\n')
+			case t.X-(prev.X+prev.W) > prev.FontSize/5:
+				b.WriteByte(' ')
+			}
+		}
+		layout.Spans = append(layout.Spans, TextSpan{
+			Offset: b.Len(),
+			Len:    len(t.S),
+			Box:    ManifestBox{X: t.X, Y: t.Y, Width: t.W, Height: t.FontSize},
+		})
+		b.WriteString(t.S)
+		prev = t
+	}
+	layout.Text = b.String()
+	return layout, nil
+}
+
+func (s *ledongthucSource) Fonts(pageNum int) ([]string, error) {
+	p, err := s.page(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	if p.V.IsNull() {
+		return nil, nil
+	}
+	var fonts []string
+	for _, name := range p.Fonts() {
+		fonts = append(fonts, p.Font(name).BaseFont())
+	}
+	return fonts, nil
+}
+
+// FontRuns interprets the page's content the way GetPlainText does, noting
+// the base font of every piece of text.
+func (s *ledongthucSource) FontRuns(pageNum int) (runs []FontRun, err error) {
+	p, err := s.page(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	if p.V.IsNull() || p.V.Key("Contents").Kind() == pdf.Null {
+		return nil, nil
+	}
+	// The content parser panics on malformed streams
+	defer func() {
+		if r := recover(); r != nil {
+			runs, err = nil, fmt.Errorf("could not read the text of page %d: %v", pageNum, r)
+		}
+	}()
+
+	fonts := make(map[string]pdf.Font)
+	for _, name := range p.Fonts() {
+		fonts[name] = p.Font(name)
+	}
+	var enc pdf.TextEncoding
+	font := ""
+	showEncoded := func(s string) {
+		if enc != nil {
+			s = enc.Decode(s)
+		}
+		runs = appendRun(runs, s, font)
+	}
+
+	pdf.Interpret(p.V.Key("Contents"), func(stk *pdf.Stack, op string) {
+		args := make([]pdf.Value, stk.Len())
+		for i := len(args) - 1; i >= 0; i-- {
+			args[i] = stk.Pop()
+		}
+		switch op {
+		case "BT":
+			runs = appendRun(runs, "\n", "")
+		case "T*":
+			showEncoded("\n")
+		case "Tf":
+			if len(args) != 2 {
+				panic("bad Tf operator")
+			}
+			if f, ok := fonts[args[0].Name()]; ok {
+				enc, font = f.Encoder(), f.BaseFont()
+			} else {
+				enc, font = nil, ""
+			}
+		case "Tj", "'", "\"":
+			if len(args) == 0 {
+				panic("bad " + op + " operator")
+			}
+			showEncoded(args[len(args)-1].RawString())
+		case "TJ":
+			v := args[0]
+			for i := 0; i < v.Len(); i++ {
+				if x := v.Index(i); x.Kind() == pdf.String {
+					showEncoded(x.RawString())
+				}
+			}
+		}
+	})
+	return runs, nil
+}
@@ -0,0 +1,88 @@
+package pdfextract
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+)
+
+// ManifestEntry describes one photo written for an input file.
+type ManifestEntry struct {
+	SourceFile  string      `json:"source_file"`
+	Page        int         `json:"page"`
+	ImageIndex  int         `json:"image_index"`
+	BBox        ManifestBox `json:"bbox"`
+	VoterID     string      `json:"voter_id"`
+	OutputFile  string      `json:"output_file"`
+	PixelWidth  int         `json:"pixel_width"`
+	PixelHeight int         `json:"pixel_height"`
+	Encoding    string      `json:"encoding"`
+	// Passthrough is set when the bytes are the image stream embedded in the PDF.
+	Passthrough bool   `json:"passthrough"`
+	SHA256      string `json:"sha256"`
+	// PhotoHash is the perceptual hash of the photo, when Options.PhotoHash is set.
+	PhotoHash string `json:"photo_hash,omitempty"`
+	// Roll is set for every photo of a file when it is written.
+	Roll *RollMetadata `json:"roll,omitempty"`
+}
+
+// ManifestBox is where the photo is drawn on the page, in PDF points from the lower left.
+type ManifestBox struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+var manifestCSVHeader = append([]string{
+	"source_file", "page", "image_index", "bbox_x", "bbox_y", "bbox_width", "bbox_height",
+	"voter_id", "output_file", "pixel_width", "pixel_height", "encoding", "passthrough", "sha256", "photo_hash",
+}, rollCSVHeader...)
+
+// Writes manifest.json and manifest.csv into dir, their names preceded by prefix.
+func writeManifest(dir, prefix string, entries []ManifestEntry) error {
+	if entries == nil {
+		entries = []ManifestEntry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(filepath.Join(dir, prefix+"manifest.json"), append(data, '\n')); err != nil {
+		return err
+	}
+	return writeManifestCSV(filepath.Join(dir, prefix+"manifest.csv"), entries)
+}
+
+func writeManifestCSV(path string, entries []ManifestEntry) error {
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	formatFloat := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+
+	w := csv.NewWriter(f)
+	if err := w.Write(manifestCSVHeader); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		row := []string{
+			e.SourceFile, strconv.Itoa(e.Page), strconv.Itoa(e.ImageIndex),
+			formatFloat(e.BBox.X), formatFloat(e.BBox.Y), formatFloat(e.BBox.Width), formatFloat(e.BBox.Height),
+			e.VoterID, e.OutputFile, strconv.Itoa(e.PixelWidth), strconv.Itoa(e.PixelHeight),
+			e.Encoding, strconv.FormatBool(e.Passthrough), e.SHA256, e.PhotoHash,
+		}
+		row = append(row, e.Roll.csvFields()...)
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Commit()
+}
@@ -0,0 +1,171 @@
+package pdfextract
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// RollMetadata is where a voter roll belongs administratively. The
+// province, district and municipality come from the file name, the ward and
+// polling centre from the cover page.
+type RollMetadata struct {
+	ProvinceNumber   string `json:"province_number,omitempty"`
+	Province         string `json:"province,omitempty"`
+	DistrictCode     string `json:"district_code,omitempty"`
+	District         string `json:"district,omitempty"`
+	MunicipalityCode string `json:"municipality_code,omitempty"`
+	Municipality     string `json:"municipality,omitempty"`
+	Ward             string `json:"ward,omitempty"`
+	PollingCentre    string `json:"polling_centre,omitempty"`
+}
+
+var rollCSVHeader = []string{
+	"province_number", "province", "district_code", "district",
+	"municipality_code", "municipality", "ward", "polling_centre",
+}
+
+func (m *RollMetadata) csvFields() []string {
+	if m == nil {
+		return make([]string, len(rollCSVHeader))
+	}
+	return []string{
+		m.ProvinceNumber, m.Province, m.DistrictCode, m.District,
+		m.MunicipalityCode, m.Municipality, m.Ward, m.PollingCentre,
+	}
+}
+
+// Reads the metadata of an open roll from its file name and cover page.
+func readRollMetadata(doc *docSession, tpl *Template) RollMetadata {
+	meta := parseRollFilename(doc.path, doc.log)
+
+	text, err := doc.pageText(1, tpl.LegacyFont)
+	if err != nil {
+		doc.log.Warn("Could not read cover page", "error", err)
+		return meta
+	}
+	parseCoverPage(text, &meta)
+	doc.log.Info("Read roll metadata", "metadata", meta)
+	return meta
+}
+
+// Parses a file name like "1_कोशी प्रदेश_4_झापा_5031_कमल गाउँपालिका.pdf":
+// province number and name, district code and name, municipality code and name.
+func parseRollFilename(path string, logger *slog.Logger) RollMetadata {
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	base, _ = normalizeDigits(norm.NFC.String(base))
+
+	parts := strings.SplitN(base, "_", 6)
+	if len(parts) != 6 || !isDigits(parts[0]) || !isDigits(parts[2]) || !isDigits(parts[4]) {
+		logger.Warn("File name does not follow <province>_<name>_<district>_<name>_<municipality>_<name>")
+		return RollMetadata{}
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return RollMetadata{
+		ProvinceNumber:   parts[0],
+		Province:         parts[1],
+		DistrictCode:     parts[2],
+		District:         parts[3],
+		MunicipalityCode: parts[4],
+		Municipality:     parts[5],
+	}
+}
+
+func isDigits(s string) bool {
+	s = strings.TrimSpace(s)
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+var (
+	coverWardPattern    = regexp.MustCompile(`वडा\s*(?:नं|नम्बर)\.?\s*[:：]?\s*(\d+)`)
+	coverCentrePattern  = regexp.MustCompile(`मतदान\s*केन्द्र(?:को\s*नाम)?\s*[:：]?[ \t]*([^\n]+)`)
+	coverValueSeparator = regexp.MustCompile(`\s{2,}`)
+)
+
+// Fills the ward and polling centre from the cover page text.
+func parseCoverPage(text string, meta *RollMetadata) {
+	if m := coverWardPattern.FindStringSubmatch(text); m != nil {
+		meta.Ward = m[1]
+	}
+	if m := coverCentrePattern.FindStringSubmatch(text); m != nil {
+		// the rest of the line may hold the next label after a wide gap
+		centre := coverValueSeparator.Split(strings.TrimSpace(m[1]), 2)[0]
+		meta.PollingCentre = strings.TrimSpace(centre)
+	}
+}
+
+/* ---------- output layout ---------- */
+
+// The output layout places each file's photos under the output directory,
+// e.g. "{province}/{district}/{municipality}/{ward}/{file}".
+const DefaultOutputLayout = "{file}"
+
+var layoutPlaceholder = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+func (m RollMetadata) layoutValues(inputPath string) map[string]string {
+	return map[string]string{
+		"file":              fileStem(inputPath),
+		"province_number":   m.ProvinceNumber,
+		"province":          m.Province,
+		"district_code":     m.DistrictCode,
+		"district":          m.District,
+		"municipality_code": m.MunicipalityCode,
+		"municipality":      m.Municipality,
+		"ward":              m.Ward,
+		"polling_centre":    m.PollingCentre,
+	}
+}
+
+func fileStem(inputPath string) string {
+	return strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+}
+
+// fileNamePrefix goes before the manifest and records file names of an
+// input file, e.g. "<file>.manifest.json", when the layout does not give
+// every file a folder of its own, so rolls sharing a folder keep theirs.
+func fileNamePrefix(layout, inputPath string) string {
+	if layout == "" || strings.Contains(layout, "{file}") {
+		return ""
+	}
+	return fileStem(inputPath) + "."
+}
+
+// Checks that a layout only uses known placeholders.
+func ValidateOutputLayout(layout string) error {
+	known := RollMetadata{}.layoutValues("")
+	for _, m := range layoutPlaceholder.FindAllStringSubmatch(layout, -1) {
+		if _, ok := known[m[1]]; !ok {
+			return fmt.Errorf("unknown placeholder {%s} in output layout %q", m[1], layout)
+		}
+	}
+	if filepath.IsAbs(layout) || strings.Contains(layout, "..") {
+		return fmt.Errorf("output layout %q must stay inside the output directory", layout)
+	}
+	return nil
+}
+
+// Expands a layout into a relative directory. Unknown values become
+// "unknown" so files that lack metadata still end up together.
+func (m RollMetadata) expandLayout(layout, inputPath string) string {
+	values := m.layoutValues(inputPath)
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(layout), "/") {
+		part = layoutPlaceholder.ReplaceAllStringFunc(part, func(p string) string {
+			v := strings.TrimSpace(values[p[1:len(p)-1]])
+			if v == "" || v == "." || v == ".." {
+				return "unknown"
+			}
+			return strings.NewReplacer("/", "-", `\`, "-").Replace(v)
+		})
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return filepath.Join(parts...)
+}
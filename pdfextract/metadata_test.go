@@ -0,0 +1,124 @@
+package pdfextract
+
+import (
+	"log/slog"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRollFilename(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	tests := []struct {
+		path string
+		want RollMetadata
+	}{
+		{
+			path: "/rolls/1_कोशी प्रदेश_4_झापा_5031_कमल गाउँपालिका.pdf",
+			want: RollMetadata{ProvinceNumber: "1", Province: "कोशी प्रदेश", DistrictCode: "4", District: "झापा",
+				MunicipalityCode: "5031", Municipality: "कमल गाउँपालिका"},
+		},
+		{
+			// Devanagari digits, and underscores kept in the municipality name
+			path: "१_कोशी प्रदेश_४_झापा_५०३१_कमल_गाउँपालिका.pdf",
+			want: RollMetadata{ProvinceNumber: "1", Province: "कोशी प्रदेश", DistrictCode: "4", District: "झापा",
+				MunicipalityCode: "5031", Municipality: "कमल_गाउँपालिका"},
+		},
+		{path: "sample.pdf"},
+		{path: "1_कोशी प्रदेश_झापा_4_5031_कमल.pdf"},
+	}
+	for _, tt := range tests {
+		if got := parseRollFilename(tt.path, logger); got != tt.want {
+			t.Errorf("parseRollFilename(%q) = %+v, want %+v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseCoverPage(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want RollMetadata
+	}{
+		{
+			name: "ward and polling centre",
+			text: "मतदाता नामावली\nवडा नं. ३\nमतदान केन्द्र: श्री जनता मा.वि.\n",
+			want: RollMetadata{Ward: "3", PollingCentre: "श्री जनता मा.वि."},
+		},
+		{
+			name: "next label after a wide gap",
+			text: "वडा नम्बर: 12\nमतदान केन्द्रको नाम: Shree School    मतदाता संख्या: 480\n",
+			want: RollMetadata{Ward: "12", PollingCentre: "Shree School"},
+		},
+		{
+			name: "neither",
+			text: "Ward 3 Polling Centre: Shree School\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got RollMetadata
+			parseCoverPage(normalizeText(tt.text), &got)
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandLayout(t *testing.T) {
+	meta := RollMetadata{Province: "कोशी प्रदेश", District: "झापा", Municipality: "कमल गाउँपालिका", Ward: "3",
+		PollingCentre: "a/b"}
+	tests := []struct {
+		layout string
+		meta   RollMetadata
+		want   string
+	}{
+		{"{file}", meta, "roll"},
+		{"{province}/{district}/{municipality}/ward-{ward}/{file}", meta,
+			filepath.Join("कोशी प्रदेश", "झापा", "कमल गाउँपालिका", "ward-3", "roll")},
+		// Slashes in values do not add folders
+		{"{polling_centre}", meta, "a-b"},
+		// Missing values still group together
+		{"{district}/{ward}", RollMetadata{}, filepath.Join("unknown", "unknown")},
+		{"//{district}//", meta, "झापा"},
+	}
+	for _, tt := range tests {
+		if got := tt.meta.expandLayout(tt.layout, "/in/roll.pdf"); got != tt.want {
+			t.Errorf("expandLayout(%q) = %q, want %q", tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestValidateOutputLayout(t *testing.T) {
+	tests := []struct {
+		layout string
+		ok     bool
+	}{
+		{DefaultOutputLayout, true},
+		{"{province_number}-{province}/{district_code}/{municipality_code}/{polling_centre}/{file}", true},
+		{"rolls/{district}", true},
+		{"{state}/{file}", false},
+		{"/abs/{file}", false},
+		{"../{file}", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateOutputLayout(tt.layout); (err == nil) != tt.ok {
+			t.Errorf("ValidateOutputLayout(%q) = %v, want ok %v", tt.layout, err, tt.ok)
+		}
+	}
+}
+
+func TestFileNamePrefix(t *testing.T) {
+	tests := []struct {
+		layout, want string
+	}{
+		{"", ""},
+		{"{district}/{file}", ""},
+		{"{province}/{district}", "roll."},
+	}
+	for _, tt := range tests {
+		if got := fileNamePrefix(tt.layout, "/in/roll.pdf"); got != tt.want {
+			t.Errorf("fileNamePrefix(%q) = %q, want %q", tt.layout, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package pdfextract
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// legacyFont names an ASCII-remapped Nepali font whose text comes out of the
+// PDF as Latin letters.
+type legacyFont string
+
+const (
+	legacyFontAuto     legacyFont = "auto"
+	legacyFontNone     legacyFont = "none"
+	legacyFontPreeti   legacyFont = "preeti"
+	legacyFontKantipur legacyFont = "kantipur"
+)
+
+// Base font names that identify a legacy font on a page.
+var legacyFontNames = map[string]legacyFont{
+	"preeti":   legacyFontPreeti,
+	"kantipur": legacyFontKantipur,
+}
+
+// legacyFontOf returns the legacy font a base font name belongs to.
+func legacyFontOf(baseFont string) legacyFont {
+	lower := strings.ToLower(baseFont)
+	for name, font := range legacyFontNames {
+		if strings.Contains(lower, name) {
+			return font
+		}
+	}
+	return legacyFontNone
+}
+
+// detectLegacyFont returns the legacy font used by any of the page's base fonts.
+func detectLegacyFont(baseFonts []string) legacyFont {
+	for _, f := range baseFonts {
+		if font := legacyFontOf(f); font != legacyFontNone {
+			return font
+		}
+	}
+	return legacyFontNone
+}
+
+// Normalizes page text before IDs and records are parsed: the text is put
+// in NFC, and every Unicode decimal digit becomes its ASCII counterpart.
+// Legacy font text must already be converted, see convertLegacyRuns.
+func normalizeText(text string) string {
+	s, _ := normalizeDigits(norm.NFC.String(text))
+	return s
+}
+
+// convertLegacyRuns joins the runs of a page's text, converting the runs set
+// in a legacy font to Unicode Devanagari and keeping the rest, such as
+// Unicode Devanagari or ASCII in other fonts, as it is. Whitespace between
+// two legacy runs is converted with them, so a word drawn in pieces is
+// converted as one. It also tells whether any run was in a legacy font.
+func convertLegacyRuns(runs []FontRun) (string, bool) {
+	var b, legacy strings.Builder
+	// Whitespace after legacy text, converted with it if more follows
+	gap := ""
+	converted := false
+	flush := func() {
+		b.WriteString(preetiToUnicode(legacy.String()))
+		legacy.Reset()
+		b.WriteString(gap)
+		gap = ""
+	}
+	for _, r := range runs {
+		switch {
+		case legacyFontOf(r.Font) != legacyFontNone:
+			legacy.WriteString(gap)
+			legacy.WriteString(r.Text)
+			gap = ""
+			converted = true
+		case legacy.Len() > 0 && strings.TrimSpace(r.Text) == "":
+			gap += r.Text
+		default:
+			flush()
+			b.WriteString(r.Text)
+		}
+	}
+	flush()
+	return b.String(), converted
+}
+
+// digitValue returns the value of a Unicode decimal digit (category Nd).
+func digitValue(r rune) (int, bool) {
+	if r >= '0' && r <= '9' {
+		return int(r - '0'), true
+	}
+	for _, rg := range unicode.Nd.R16 {
+		if rune(rg.Lo) <= r && r <= rune(rg.Hi) && rg.Stride == 1 {
+			return int(r-rune(rg.Lo)) % 10, true
+		}
+	}
+	for _, rg := range unicode.Nd.R32 {
+		if rune(rg.Lo) <= r && r <= rune(rg.Hi) && rg.Stride == 1 {
+			return int(r-rune(rg.Lo)) % 10, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeDigits replaces every Unicode decimal digit with its ASCII
+// digit. It also returns, for each byte of the result, the offset of the
+// byte in s it came from, so matches can be mapped back to the original.
+func normalizeDigits(s string) (string, []int) {
+	var b strings.Builder
+	b.Grow(len(s))
+	offsets := make([]int, 0, len(s)+1)
+	for i, r := range s {
+		if v, ok := digitValue(r); ok {
+			b.WriteByte(byte('0' + v))
+			offsets = append(offsets, i)
+			continue
+		}
+		n, _ := b.WriteRune(r)
+		for k := 0; k < n; k++ {
+			offsets = append(offsets, i+k)
+		}
+	}
+	offsets = append(offsets, len(s))
+	return b.String(), offsets
+}
+
+/* ---------- Preeti / Kantipur ---------- */
+
+// Kantipur uses the same keyboard layout as Preeti.
+var preetiReplacer = strings.NewReplacer(
+	// multi-character sequences first
+	"Qm", "क्त", "qm", "क्र", "km", "फ",
+	"cf}", "औ", "cf]", "ओ", "cf", "आ", "O{", "ई", "P]", "ऐ", "p\"", "ऊ",
+
+	"a", "ब", "b", "द", "c", "अ", "d", "म", "e", "भ", "f", "ा", "g", "न", "h", "ज", "i", "ष्",
+	"j", "व", "k", "प", "l", "ि", "m", "ः", "n", "ल", "o", "य", "p", "उ", "q", "त्र", "r", "च",
+	"s", "क", "t", "त", "u", "ग", "v", "ख", "w", "ध", "x", "ह", "y", "थ", "z", "श",
+
+	"A", "ब्", "B", "द्य", "C", "ऋ", "D", "म्", "E", "भ्", "F", "ँ", "G", "न्", "H", "ज्",
+	"I", "क्ष्", "J", "व्", "K", "प्", "L", "ी", "M", "ः", "N", "ल्", "O", "इ", "P", "ए",
+	"Q", "त्त", "R", "च्", "S", "क्", "T", "त्", "U", "ग्", "V", "ख्", "W", "ध्", "X", "ह्",
+	"Y", "थ्", "Z", "श्",
+
+	"0", "०", "1", "१", "2", "२", "3", "३", "4", "४", "5", "५", "6", "६", "7", "७", "8", "८", "9", "९",
+
+	"`", "ञ", "~", "ञ्", "!", "ज्ञ", "@", "द्द", "#", "घ", "$", "द्ध", "%", "छ", "^", "ट",
+	"&", "ठ", "*", "ड", "(", "ढ", ")", "ण", "-", "(", "_", ")", "=", ".", "+", "ं",
+	"[", "ृ", "{", "र्", "]", "े", "}", "ै", "\\", "्", "|", "्र", ";", "स", ":", "स्",
+	"'", "ु", "\"", "ू", ",", ",", "<", "?", ".", "।", ">", "श्र", "/", "र", "?", "रु",
+
+	"Ë", "ङ्ग", "Ì", "न्न", "Í", "ङ्क", "Î", "ङ्ख", "§", "ट्ट", "°", "ङ्ढ", "Å", "हृ",
+	"Ø", "्य", "å", "द्व", "ç", "ॐ", "Ö", "=", "Ù", ";", "Û", "!", "Ü", "%",
+)
+
+var (
+	// ि is typed before the consonant (cluster) it follows in Unicode.
+	preetiIKar = regexp.MustCompile(`ि((?:[\p{L}\p{M}]्)*[\p{L}])`)
+	// र् (reph) is typed after the syllable it precedes in Unicode.
+	preetiReph = regexp.MustCompile(`((?:[\p{L}]्)*[\p{L}][ािीुूृेैोौंँः]*)र्`)
+)
+
+// preetiToUnicode converts text typed in the Preeti (or Kantipur) layout to
+// Unicode Devanagari.
+func preetiToUnicode(s string) string {
+	s = preetiReplacer.Replace(s)
+	s = preetiIKar.ReplaceAllString(s, "${1}ि")
+	s = preetiReph.ReplaceAllString(s, "र्${1}")
+	// a half letter followed by ा is the full letter
+	s = strings.ReplaceAll(s, "्ा", "")
+	// vowel sign compositions
+	s = strings.NewReplacer("ाे", "ो", "ाै", "ौ", "अा", "आ", "आे", "ओ", "आै", "औ").Replace(s)
+	return s
+}
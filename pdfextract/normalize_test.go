@@ -0,0 +1,182 @@
+package pdfextract
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeDigits(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"12345678", "12345678"},
+		{"१२३४५६७८", "12345678"},
+		{"मतदाता नं: ५०१२३४५६", "मतदाता नं: 50123456"},
+		// Other scripts' decimal digits too: Bengali and full-width
+		{"৪২ ４２", "42 42"},
+		{"क.सं. १२", "क.सं. 12"},
+	}
+	for _, tt := range tests {
+		got, offsets := normalizeDigits(tt.in)
+		if got != tt.want {
+			t.Errorf("normalizeDigits(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if len(offsets) != len(got)+1 || offsets[len(got)] != len(tt.in) {
+			t.Errorf("normalizeDigits(%q): %d offsets ending at %d, want %d ending at %d",
+				tt.in, len(offsets), offsets[len(offsets)-1], len(got)+1, len(tt.in))
+		}
+	}
+}
+
+func TestNormalizeDigitsOffsets(t *testing.T) {
+	// Each Devanagari digit is 3 bytes and becomes 1, the letters keep theirs
+	in := "नं ५०१२"
+	got, offsets := normalizeDigits(in)
+	if got != "नं 5012" {
+		t.Fatalf("got %q", got)
+	}
+	start := len("नं ")
+	// The ID's bytes map back to the start of each Devanagari digit
+	for i := 0; i < 4; i++ {
+		if want := start + 3*i; offsets[start+i] != want {
+			t.Errorf("offset of digit %d = %d, want %d", i, offsets[start+i], want)
+		}
+	}
+	// Its end maps to the end of the original text
+	if offsets[start+4] != len(in) {
+		t.Errorf("end offset = %d, want %d", offsets[start+4], len(in))
+	}
+	// The letters before map byte for byte
+	for i := 0; i < start; i++ {
+		if offsets[i] != i {
+			t.Errorf("offset %d = %d, want %d", i, offsets[i], i)
+		}
+	}
+}
+
+func TestPreetiToUnicode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"g]kfn", "नेपाल"},
+		// ि is typed before the consonant cluster it follows
+		{"lxGbL", "हिन्दी"},
+		// र् is typed after the syllable it precedes
+		{"wd{", "धर्म"},
+		{"123", "१२३"},
+		{"cf}", "औ"},
+	}
+	for _, tt := range tests {
+		if got := preetiToUnicode(tt.in); got != tt.want {
+			t.Errorf("preetiToUnicode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConvertLegacyRuns(t *testing.T) {
+	tests := []struct {
+		name      string
+		runs      []FontRun
+		want      string
+		converted bool
+	}{
+		{
+			name: "only the Preeti runs are converted",
+			runs: []FontRun{
+				{Text: "dtbftf g+=", Font: "Preeti"},
+				{Text: " 50123456", Font: "Helvetica"},
+				{Text: "\n", Font: ""},
+				{Text: "नाम: राम", Font: "Kalimati"},
+			},
+			want:      "मतदाता नं. 50123456\nनाम: राम",
+			converted: true,
+		},
+		{
+			name: "a word split across runs is converted as one",
+			runs: []FontRun{
+				{Text: "l", Font: "ABCDEF+Preeti"},
+				{Text: " ", Font: ""},
+				{Text: "x", Font: "ABCDEF+Preeti"},
+			},
+			want:      "ि ह",
+			converted: true,
+		},
+		{
+			name: "whitespace before other text is kept",
+			runs: []FontRun{
+				{Text: "g]kfn", Font: "Kantipur"},
+				{Text: "\n", Font: ""},
+				{Text: "ID 123", Font: "Arial"},
+			},
+			want:      "नेपाल\nID 123",
+			converted: true,
+		},
+		{
+			name:      "no legacy font",
+			runs:      []FontRun{{Text: "g]kfn 123", Font: "Helvetica"}},
+			want:      "g]kfn 123",
+			converted: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, converted := convertLegacyRuns(tt.runs)
+			if got != tt.want || converted != tt.converted {
+				t.Errorf("convertLegacyRuns() = %q, %v, want %q, %v", got, converted, tt.want, tt.converted)
+			}
+		})
+	}
+}
+
+func TestDetectLegacyFont(t *testing.T) {
+	tests := []struct {
+		fonts []string
+		want  legacyFont
+	}{
+		{[]string{"Helvetica", "ABCDEF+Preeti"}, legacyFontPreeti},
+		{[]string{"KANTIPUR"}, legacyFontKantipur},
+		{[]string{"Kalimati", "Arial"}, legacyFontNone},
+		{nil, legacyFontNone},
+	}
+	for _, tt := range tests {
+		if got := detectLegacyFont(tt.fonts); got != tt.want {
+			t.Errorf("detectLegacyFont(%v) = %q, want %q", tt.fonts, got, tt.want)
+		}
+	}
+}
+
+func TestFontRuns(t *testing.T) {
+	path := writeTestRoll(t, 2)
+	ex, err := New(Options{Backend: OfflineBackend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := openDocSession(path, ex.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	runs, err := doc.text.FontRuns(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The runs hold the plain text, cut between the inserted line breaks
+	// and the Helvetica text
+	plain, err := doc.text.PlainText(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	joined := ""
+	fonts := make(map[string]bool)
+	for _, r := range runs {
+		joined += r.Text
+		fonts[r.Font] = true
+	}
+	if joined != plain {
+		t.Errorf("joined runs differ from the plain text:\n%q\n%q", joined, plain)
+	}
+	if want := map[string]bool{"": true, "Helvetica": true}; !reflect.DeepEqual(fonts, want) {
+		t.Errorf("fonts = %v, want %v", fonts, want)
+	}
+}
@@ -0,0 +1,535 @@
+package pdfextract
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+
+	"github.com/unidoc/unipdf/v4/contentstream"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// offlineSource reads the images of a page without unipdf's extractor, so
+// it works without a license and without reaching the metering service. It
+// only uses unipdf's PDF parser to follow the page's content stream to its
+// image XObjects, and decodes their DCT and Flate streams itself.
+type offlineSource struct {
+	reader *model.PdfReader
+	// keepRaw keeps the embedded stream of JPEG images, so they can be
+	// written unchanged.
+	keepRaw bool
+}
+
+// Form XObjects may draw other forms; deeper nesting than this is taken
+// to be a loop.
+const maxFormDepth = 16
+
+func newOfflineSource(f *os.File, keepRaw bool) (*offlineSource, int, error) {
+	reader, err := model.NewPdfReaderWithOpts(f, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, 0, err
+	}
+	return &offlineSource{reader: reader, keepRaw: keepRaw}, numPages, nil
+}
+
+func (s *offlineSource) Images(pageNum int) (*PageImages, error) {
+	page, err := s.reader.GetPage(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	mediaBox, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	content, err := page.GetAllContentStreams()
+	if err != nil {
+		return nil, err
+	}
+	images := &PageImages{MediaBox: manifestBox(*mediaBox)}
+	if err := s.drawContent(content, page.Resources, identityMatrix, images, 0); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// drawContent follows the graphics state of a content stream and adds every
+// image it draws, where it is drawn.
+func (s *offlineSource) drawContent(content string, res *model.PdfPageResources, ctm matrix, images *PageImages, depth int) error {
+	if depth > maxFormDepth {
+		return fmt.Errorf("form XObjects nested more than %d deep", maxFormDepth)
+	}
+	ops, err := contentstream.NewContentStreamParser(content).Parse()
+	if err != nil {
+		return err
+	}
+
+	var saved []matrix
+	for _, op := range *ops {
+		switch op.Operand {
+		case "q":
+			saved = append(saved, ctm)
+		case "Q":
+			if len(saved) > 0 {
+				ctm = saved[len(saved)-1]
+				saved = saved[:len(saved)-1]
+			}
+		case "cm":
+			m, err := core.GetNumbersAsFloat(op.Params)
+			if err != nil || len(m) != 6 {
+				continue
+			}
+			ctm = matrix(m).mul(ctm)
+		case "Do":
+			if res == nil || len(op.Params) != 1 {
+				continue
+			}
+			name, ok := core.GetName(op.Params[0])
+			if !ok {
+				continue
+			}
+			stream, xtype := res.GetXObjectByName(*name)
+			switch xtype {
+			case model.XObjectTypeImage:
+				img, err := decodeImageXObject(stream, s.keepRaw)
+				if err != nil {
+					return fmt.Errorf("image %s: %v", *name, err)
+				}
+				img.Box = ctm.unitBox()
+				images.Images = append(images.Images, img)
+			case model.XObjectTypeForm:
+				form, err := model.NewXObjectFormFromStream(stream)
+				if err != nil {
+					return fmt.Errorf("form %s: %v", *name, err)
+				}
+				formContent, err := form.GetContentStream()
+				if err != nil {
+					return fmt.Errorf("form %s: %v", *name, err)
+				}
+				formCTM := ctm
+				if arr, ok := core.GetArray(form.Matrix); ok {
+					if m, err := arr.ToFloat64Array(); err == nil && len(m) == 6 {
+						formCTM = matrix(m).mul(ctm)
+					}
+				}
+				// Forms without resources use those of the page
+				formRes := form.Resources
+				if formRes == nil {
+					formRes = res
+				}
+				if err := s.drawContent(string(formContent), formRes, formCTM, images, depth+1); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+/* ---------- transformation matrices ---------- */
+
+// matrix is a PDF transformation matrix [a b c d e f].
+type matrix []float64
+
+var identityMatrix = matrix{1, 0, 0, 1, 0, 0}
+
+// mul returns m followed by n, the way cm concatenates m to the CTM n.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+// unitBox is the bounding box of the unit square, where images are drawn.
+func (m matrix) unitBox() ManifestBox {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, p := range [][2]float64{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+		x := p[0]*m[0] + p[1]*m[2] + m[4]
+		y := p[0]*m[1] + p[1]*m[3] + m[5]
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	return ManifestBox{X: minX, Y: minY, Width: maxX - minX, Height: maxY - minY}
+}
+
+/* ---------- image decoding ---------- */
+
+// decodeImageXObject decodes an image stored as JPEG, Flate-compressed or
+// uncompressed samples. Other encodings, such as JPEG 2000, need unipdf.
+func decodeImageXObject(stream *core.PdfObjectStream, keepRaw bool) (PageImage, error) {
+	filters, parms := streamFilters(stream)
+	data := stream.Stream
+	for i, filter := range filters {
+		switch filter {
+		case core.StreamEncodingFilterNameFlate:
+			var err error
+			if data, err = inflate(data, parms[i]); err != nil {
+				return PageImage{}, err
+			}
+		case core.StreamEncodingFilterNameDCT:
+			if i != len(filters)-1 {
+				return PageImage{}, fmt.Errorf("unsupported filters %v", filters)
+			}
+			img, err := jpeg.Decode(bytes.NewReader(data))
+			if err != nil {
+				return PageImage{}, err
+			}
+			// The same pixel type as unipdf gives, so photos encode alike
+			if ycc, ok := img.(*image.YCbCr); ok {
+				rgba := image.NewRGBA(ycc.Bounds())
+				draw.Draw(rgba, rgba.Bounds(), ycc, ycc.Bounds().Min, draw.Src)
+				img = rgba
+			}
+			pi := PageImage{Image: img}
+			if keepRaw && len(filters) == 1 {
+				raw := passthroughFilters[filter]
+				raw.Data = data
+				pi.Raw = &raw
+			}
+			return pi, nil
+		default:
+			return PageImage{}, fmt.Errorf("unsupported filter %s (needs the unipdf image backend)", filter)
+		}
+	}
+
+	img, err := decodeSamples(stream.PdfObjectDictionary, data)
+	if err != nil {
+		return PageImage{}, err
+	}
+	return PageImage{Image: img}, nil
+}
+
+// streamFilters returns the filters of a stream in the order they are
+// applied, with their decode parameters (nil when there are none).
+func streamFilters(stream *core.PdfObjectStream) ([]core.PdfObjectName, []*core.PdfObjectDictionary) {
+	filterObj := core.TraceToDirectObject(stream.Get("Filter"))
+	parmsObj := core.TraceToDirectObject(stream.Get("DecodeParms"))
+	if name, ok := core.GetName(filterObj); ok {
+		parms, _ := core.GetDict(parmsObj)
+		return []core.PdfObjectName{*name}, []*core.PdfObjectDictionary{parms}
+	}
+
+	arr, ok := core.GetArray(filterObj)
+	if !ok {
+		return nil, nil
+	}
+	parmsArr, _ := core.GetArray(parmsObj)
+	var filters []core.PdfObjectName
+	var parms []*core.PdfObjectDictionary
+	for i, obj := range arr.Elements() {
+		name, ok := core.GetName(obj)
+		if !ok {
+			continue
+		}
+		var p *core.PdfObjectDictionary
+		if parmsArr != nil && i < parmsArr.Len() {
+			p, _ = core.GetDict(parmsArr.Get(i))
+		}
+		filters = append(filters, *name)
+		parms = append(parms, p)
+	}
+	return filters, parms
+}
+
+// inflate decompresses a Flate stream and undoes its predictor.
+func inflate(data []byte, parms *core.PdfObjectDictionary) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(zr)
+	// Streams cut short or with a bad checksum still hold usable samples
+	if err != nil && len(out) == 0 {
+		return nil, err
+	}
+
+	predictor := dictInt(parms, "Predictor", 1)
+	if predictor == 1 {
+		return out, nil
+	}
+	colors := dictInt(parms, "Colors", 1)
+	bpc := dictInt(parms, "BitsPerComponent", 8)
+	columns := dictInt(parms, "Columns", 1)
+	rowLen := (colors*bpc*columns + 7) / 8
+	bpp := max(1, colors*bpc/8)
+	if rowLen <= 0 {
+		return nil, fmt.Errorf("invalid predictor parameters")
+	}
+
+	if predictor == 2 {
+		if bpc != 8 {
+			return nil, fmt.Errorf("unsupported TIFF predictor with %d bits per component", bpc)
+		}
+		for row := 0; row+rowLen <= len(out); row += rowLen {
+			for i := row + colors; i < row+rowLen; i++ {
+				out[i] += out[i-colors]
+			}
+		}
+		return out, nil
+	}
+
+	// PNG predictors: every row starts with its filter type
+	var rows []byte
+	prev := make([]byte, rowLen)
+	for pos := 0; pos+1+rowLen <= len(out); pos += 1 + rowLen {
+		filter, cur := out[pos], out[pos+1:pos+1+rowLen]
+		for i := range cur {
+			var left, upLeft byte
+			if i >= bpp {
+				left, upLeft = cur[i-bpp], prev[i-bpp]
+			}
+			up := prev[i]
+			switch filter {
+			case 1:
+				cur[i] += left
+			case 2:
+				cur[i] += up
+			case 3:
+				cur[i] += byte((int(left) + int(up)) / 2)
+			case 4:
+				cur[i] += paeth(left, up, upLeft)
+			}
+		}
+		rows = append(rows, cur...)
+		prev = cur
+	}
+	return rows, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+// imageColorSpace is the colour space of an image's samples.
+type imageColorSpace struct {
+	// model is color.GrayModel, color.RGBAModel or color.CMYKModel.
+	model      color.Model
+	components int
+	// base and lookup describe an Indexed space, whose samples are
+	// indexes into lookup, base.components bytes per entry.
+	base   *imageColorSpace
+	lookup []byte
+}
+
+func parseColorSpace(obj core.PdfObject) (*imageColorSpace, error) {
+	obj = core.TraceToDirectObject(obj)
+	if name, ok := core.GetName(obj); ok {
+		switch *name {
+		case "DeviceGray", "CalGray", "G":
+			return &imageColorSpace{model: color.GrayModel, components: 1}, nil
+		case "DeviceRGB", "CalRGB", "RGB":
+			return &imageColorSpace{model: color.RGBAModel, components: 3}, nil
+		case "DeviceCMYK", "CMYK":
+			return &imageColorSpace{model: color.CMYKModel, components: 4}, nil
+		}
+		return nil, fmt.Errorf("unsupported color space %s", *name)
+	}
+
+	arr, ok := core.GetArray(obj)
+	if !ok || arr.Len() == 0 {
+		return nil, fmt.Errorf("missing color space")
+	}
+	family, _ := core.GetName(core.TraceToDirectObject(arr.Get(0)))
+	if family == nil {
+		return nil, fmt.Errorf("invalid color space")
+	}
+	switch *family {
+	case "CalGray", "CalRGB", "DeviceGray", "DeviceRGB", "DeviceCMYK":
+		return parseColorSpace(family)
+	case "ICCBased":
+		// The profile is ignored; its number of components picks the
+		// device space it stands in for
+		if arr.Len() < 2 {
+			break
+		}
+		profile, ok := core.GetStream(core.TraceToDirectObject(arr.Get(1)))
+		if !ok {
+			break
+		}
+		switch dictInt(profile.PdfObjectDictionary, "N", 0) {
+		case 1:
+			return parseColorSpace(core.MakeName("DeviceGray"))
+		case 3:
+			return parseColorSpace(core.MakeName("DeviceRGB"))
+		case 4:
+			return parseColorSpace(core.MakeName("DeviceCMYK"))
+		}
+	case "Indexed", "I":
+		if arr.Len() < 4 {
+			break
+		}
+		base, err := parseColorSpace(arr.Get(1))
+		if err != nil {
+			return nil, err
+		}
+		lookupObj := core.TraceToDirectObject(arr.Get(3))
+		lookup, ok := core.GetStringBytes(lookupObj)
+		if stream, isStream := core.GetStream(lookupObj); isStream {
+			if lookup, err = decodeLookupStream(stream); err != nil {
+				return nil, err
+			}
+			ok = true
+		}
+		if !ok {
+			break
+		}
+		return &imageColorSpace{model: base.model, components: 1, base: base, lookup: lookup}, nil
+	}
+	return nil, fmt.Errorf("unsupported color space %s", *family)
+}
+
+func decodeLookupStream(stream *core.PdfObjectStream) ([]byte, error) {
+	filters, parms := streamFilters(stream)
+	data := stream.Stream
+	for i, filter := range filters {
+		if filter != core.StreamEncodingFilterNameFlate {
+			return nil, fmt.Errorf("unsupported filter %s in color lookup table", filter)
+		}
+		var err error
+		if data, err = inflate(data, parms[i]); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// decodeSamples builds an image from the decoded samples of an image XObject.
+func decodeSamples(dict *core.PdfObjectDictionary, data []byte) (image.Image, error) {
+	width := dictInt(dict, "Width", 0)
+	height := dictInt(dict, "Height", 0)
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", width, height)
+	}
+
+	bpc := dictInt(dict, "BitsPerComponent", 8)
+	var cs *imageColorSpace
+	if mask, _ := core.GetBoolVal(core.TraceToDirectObject(dict.Get("ImageMask"))); mask {
+		// Stencil masks paint where their samples are 0
+		bpc = 1
+		cs = &imageColorSpace{model: color.GrayModel, components: 1}
+	} else {
+		var err error
+		if cs, err = parseColorSpace(dict.Get("ColorSpace")); err != nil {
+			return nil, err
+		}
+	}
+	switch bpc {
+	case 1, 2, 4, 8, 16:
+	default:
+		return nil, fmt.Errorf("unsupported %d bits per component", bpc)
+	}
+
+	rowLen := (width*cs.components*bpc + 7) / 8
+	if len(data) < rowLen*height {
+		return nil, fmt.Errorf("image data too short: %d bytes for %dx%d", len(data), width, height)
+	}
+	// Decode ranges of each component, from the Decode array
+	decode := make([]float64, 0, 2*cs.components)
+	if arr, ok := core.GetArray(core.TraceToDirectObject(dict.Get("Decode"))); ok && cs.base == nil {
+		decode, _ = arr.ToFloat64Array()
+	}
+	if len(decode) != 2*cs.components {
+		decode = decode[:0]
+		for i := 0; i < cs.components; i++ {
+			decode = append(decode, 0, 1)
+		}
+	}
+
+	maxSample := float64(int(1)<<bpc - 1)
+	sample := func(row []byte, i int) int {
+		switch bpc {
+		case 8:
+			return int(row[i])
+		case 16:
+			return int(row[2*i])<<8 | int(row[2*i+1])
+		}
+		bit := i * bpc
+		return int(row[bit/8]>>(8-bpc-bit%8)) & (1<<bpc - 1)
+	}
+	// Scales a sample of component c to 0-255 through its decode range
+	scale := func(v, c int) uint8 {
+		f := decode[2*c] + float64(v)/maxSample*(decode[2*c+1]-decode[2*c])
+		return uint8(math.Round(math.Max(0, math.Min(1, f)) * 255))
+	}
+
+	rect := image.Rect(0, 0, width, height)
+	out := cs.model
+	if cs.base != nil {
+		out = cs.base.model
+	}
+	var img interface {
+		image.Image
+		Set(x, y int, c color.Color)
+	}
+	switch out {
+	case color.GrayModel:
+		img = image.NewGray(rect)
+	case color.CMYKModel:
+		img = image.NewCMYK(rect)
+	default:
+		img = image.NewRGBA(rect)
+	}
+
+	comps := make([]uint8, 4)
+	for y := 0; y < height; y++ {
+		row := data[y*rowLen : (y+1)*rowLen]
+		for x := 0; x < width; x++ {
+			if cs.base != nil {
+				n := cs.base.components
+				idx := sample(row, x) * n
+				if idx+n > len(cs.lookup) {
+					idx = 0
+				}
+				copy(comps, cs.lookup[idx:min(idx+n, len(cs.lookup))])
+			} else {
+				for c := 0; c < cs.components; c++ {
+					comps[c] = scale(sample(row, x*cs.components+c), c)
+				}
+			}
+			switch out {
+			case color.GrayModel:
+				img.Set(x, y, color.Gray{Y: comps[0]})
+			case color.CMYKModel:
+				img.Set(x, y, color.CMYK{C: comps[0], M: comps[1], Y: comps[2], K: comps[3]})
+			default:
+				img.Set(x, y, color.RGBA{R: comps[0], G: comps[1], B: comps[2], A: 255})
+			}
+		}
+	}
+	return img, nil
+}
+
+func dictInt(dict *core.PdfObjectDictionary, key core.PdfObjectName, def int) int {
+	if dict == nil {
+		return def
+	}
+	if v, ok := core.GetIntVal(core.TraceToDirectObject(dict.Get(key))); ok {
+		return v
+	}
+	return def
+}
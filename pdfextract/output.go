@@ -0,0 +1,221 @@
+package pdfextract
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+/* ---------- atomic writes ---------- */
+
+// atomicFile is written under a temporary name next to its final path and
+// renamed into place by Commit, so an interrupted run never leaves a
+// truncated file that looks complete.
+type atomicFile struct {
+	*os.File
+	path      string
+	committed bool
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: f, path: path}, nil
+}
+
+// Commit flushes the file to disk and moves it to its final path.
+func (f *atomicFile) Commit() error {
+	if err := f.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0644); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	f.committed = true
+	return nil
+}
+
+// Abort discards the file unless it was committed; safe to defer.
+func (f *atomicFile) Abort() {
+	if f.committed {
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// WriteFileAtomic writes data to path through a temporary file renamed into
+// place, the way every output of the package is written.
+func WriteFileAtomic(path string, data []byte) error {
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+/* ---------- collisions ---------- */
+
+// CollisionPolicy says what happens when two photos of a run get the same
+// output file, e.g. a voter listed twice or a misread ID, or when a photo
+// would replace a different file left by an earlier run.
+type CollisionPolicy string
+
+const (
+	// Overwrite the earlier photo with the later one.
+	CollisionOverwrite CollisionPolicy = "overwrite"
+	// Write the later photo as <id>_2.jpg, <id>_3.jpg, ...
+	CollisionSuffix CollisionPolicy = "suffix"
+	// Keep the earlier photo and drop the later one.
+	CollisionSkip CollisionPolicy = "skip"
+	// Keep the earlier photo and fail the page of the later one.
+	CollisionFail CollisionPolicy = "fail"
+)
+
+// ParseCollisionPolicy parses overwrite, suffix, skip or fail.
+func ParseCollisionPolicy(s string) (CollisionPolicy, error) {
+	switch p := CollisionPolicy(s); p {
+	case CollisionOverwrite, CollisionSuffix, CollisionSkip, CollisionFail:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown collision policy %q (want overwrite, suffix, skip or fail)", s)
+}
+
+// PhotoSource is where in the input a photo came from.
+type PhotoSource struct {
+	File       string `json:"file"`
+	Page       int    `json:"page"`
+	ImageIndex int    `json:"image_index"`
+	VoterID    string `json:"voter_id"`
+	// EarlierRun marks a file found on disk that this run did not write;
+	// where it came from is not known.
+	EarlierRun bool `json:"earlier_run,omitempty"`
+}
+
+func photoSourceOf(e ManifestEntry) PhotoSource {
+	return PhotoSource{File: e.SourceFile, Page: e.Page, ImageIndex: e.ImageIndex, VoterID: e.VoterID}
+}
+
+// Collision is one photo whose output file was already taken in the run or
+// held a different file on disk.
+type Collision struct {
+	OutputFile string          `json:"output_file"`
+	Policy     CollisionPolicy `json:"policy"`
+	Existing   PhotoSource     `json:"existing"`
+	New        PhotoSource     `json:"new"`
+	// WrittenAs is the file the new photo went to under the suffix policy.
+	WrittenAs string `json:"written_as,omitempty"`
+}
+
+// outputRegistry remembers which photo every output path of the run was
+// written from, across all input files, and which paths held other files
+// before the run.
+type outputRegistry struct {
+	mu    sync.Mutex
+	paths map[string]PhotoSource
+}
+
+func newOutputRegistry() *outputRegistry {
+	return &outputRegistry{paths: make(map[string]PhotoSource)}
+}
+
+// claim takes path for src, or returns the photo that already has it. A
+// file already on disk with other content than sum (a SHA-256) counts as taken.
+func (r *outputRegistry) claim(path string, src PhotoSource, sum string) (PhotoSource, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if prev, ok := r.paths[path]; ok {
+		return prev, true
+	}
+	if differsOnDisk(path, sum) {
+		prev := PhotoSource{EarlierRun: true}
+		r.paths[path] = prev
+		return prev, true
+	}
+	r.paths[path] = src
+	return PhotoSource{}, false
+}
+
+// differsOnDisk tells whether path holds a file whose content does not have
+// the given SHA-256. The same content is the same photo written by an
+// earlier run of the same input, which is rewritten without a collision.
+func differsOnDisk(path, sum string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)) != sum
+}
+
+func (r *outputRegistry) set(path string, src PhotoSource) {
+	r.mu.Lock()
+	r.paths[path] = src
+	r.mu.Unlock()
+}
+
+// Reserves the output file of a photo, applying the collision policy when
+// another photo of the run already has it or another file is on disk there.
+// It returns the file name to write, or "" when the photo is dropped.
+func (o *fileOutput) claimOutput(e ManifestEntry) (string, error) {
+	src := photoSourceOf(e)
+	path := filepath.Join(o.pdfDir, e.OutputFile)
+	prev, taken := o.outputs.claim(path, src, e.SHA256)
+	if !taken {
+		return e.OutputFile, nil
+	}
+
+	c := Collision{OutputFile: path, Policy: o.opts.OnCollision, Existing: prev, New: src}
+	defer func() {
+		o.report.addCollision(c)
+		if prev.EarlierRun {
+			o.log.Warn("Output file collision with a file from an earlier run", "page", src.Page, "image_index", src.ImageIndex,
+				"output_file", path, "policy", c.Policy)
+			return
+		}
+		o.log.Warn("Output file collision", "page", src.Page, "image_index", src.ImageIndex, "output_file", path,
+			"existing_file", prev.File, "existing_page", prev.Page, "existing_image_index", prev.ImageIndex, "policy", c.Policy)
+	}()
+
+	switch o.opts.OnCollision {
+	case CollisionSuffix:
+		ext := filepath.Ext(e.OutputFile)
+		base := strings.TrimSuffix(e.OutputFile, ext)
+		for n := 2; ; n++ {
+			name := fmt.Sprintf("%s_%d%s", base, n, ext)
+			if _, taken := o.outputs.claim(filepath.Join(o.pdfDir, name), src, e.SHA256); !taken {
+				c.WrittenAs = name
+				return name, nil
+			}
+		}
+	case CollisionSkip:
+		return "", nil
+	case CollisionFail:
+		if prev.EarlierRun {
+			return "", WithCategory(CategoryCollision, fmt.Errorf("%s already holds a different file from an earlier run", path))
+		}
+		return "", WithCategory(CategoryCollision, fmt.Errorf("%s was already written from %s page %d image %d",
+			path, prev.File, prev.Page, prev.ImageIndex))
+	default:
+		o.outputs.set(path, src)
+		return e.OutputFile, nil
+	}
+}
@@ -0,0 +1,117 @@
+package pdfextract
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClaimOutput(t *testing.T) {
+	sum := func(data string) string { return fmt.Sprintf("%x", sha256.Sum256([]byte(data))) }
+	photo := func(page int, data string) ManifestEntry {
+		return ManifestEntry{SourceFile: "roll.pdf", Page: page, VoterID: "50123456", OutputFile: "50123456.jpg", SHA256: sum(data)}
+	}
+
+	tests := []struct {
+		name   string
+		policy CollisionPolicy
+		// onDisk is left at the output file by an earlier run, when set
+		onDisk string
+		photos []ManifestEntry
+		want   []string
+		// failed is the photo whose claim fails, -1 for none
+		failed     int
+		collisions int
+		earlierRun bool
+	}{
+		{
+			name: "overwrite", policy: CollisionOverwrite,
+			photos: []ManifestEntry{photo(2, "a"), photo(3, "b")},
+			want:   []string{"50123456.jpg", "50123456.jpg"}, failed: -1, collisions: 1,
+		},
+		{
+			name: "suffix", policy: CollisionSuffix,
+			photos: []ManifestEntry{photo(2, "a"), photo(3, "b"), photo(4, "c")},
+			want:   []string{"50123456.jpg", "50123456_2.jpg", "50123456_3.jpg"}, failed: -1, collisions: 2,
+		},
+		{
+			name: "skip", policy: CollisionSkip,
+			photos: []ManifestEntry{photo(2, "a"), photo(3, "b")},
+			want:   []string{"50123456.jpg", ""}, failed: -1, collisions: 1,
+		},
+		{
+			name: "fail", policy: CollisionFail,
+			photos: []ManifestEntry{photo(2, "a"), photo(3, "b")},
+			want:   []string{"50123456.jpg", ""}, failed: 1, collisions: 1,
+		},
+		{
+			// Rerunning the same roll rewrites its photos quietly
+			name: "same file from an earlier run", policy: CollisionFail, onDisk: "a",
+			photos: []ManifestEntry{photo(2, "a")},
+			want:   []string{"50123456.jpg"}, failed: -1,
+		},
+		{
+			name: "other file from an earlier run, suffix", policy: CollisionSuffix, onDisk: "old",
+			photos: []ManifestEntry{photo(2, "a")},
+			want:   []string{"50123456_2.jpg"}, failed: -1, collisions: 1, earlierRun: true,
+		},
+		{
+			name: "other file from an earlier run, skip", policy: CollisionSkip, onDisk: "old",
+			photos: []ManifestEntry{photo(2, "a")},
+			want:   []string{""}, failed: -1, collisions: 1, earlierRun: true,
+		},
+		{
+			name: "other file from an earlier run, fail", policy: CollisionFail, onDisk: "old",
+			photos: []ManifestEntry{photo(2, "a")},
+			want:   []string{""}, failed: 0, collisions: 1, earlierRun: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &fileOutput{
+				pdfDir:  t.TempDir(),
+				opts:    Options{OnCollision: tt.policy},
+				report:  NewReport(SkipPage),
+				outputs: newOutputRegistry(),
+				log:     slog.New(slog.DiscardHandler),
+			}
+			if tt.onDisk != "" {
+				if err := os.WriteFile(filepath.Join(o.pdfDir, "50123456.jpg"), []byte(tt.onDisk), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var got []string
+			for i, e := range tt.photos {
+				name, err := o.claimOutput(e)
+				if (err != nil) != (i == tt.failed) {
+					t.Errorf("photo %d: error %v", i, err)
+				}
+				if err != nil && errorCategory(err) != CategoryCollision {
+					t.Errorf("photo %d: error category %q, want %q", i, errorCategory(err), CategoryCollision)
+				}
+				got = append(got, name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("names = %q, want %q", got, tt.want)
+			}
+
+			collisions := o.report.Collisions
+			if len(collisions) != tt.collisions {
+				t.Fatalf("%d collisions, want %d", len(collisions), tt.collisions)
+			}
+			for _, c := range collisions {
+				if c.Existing.EarlierRun != tt.earlierRun || c.Policy != tt.policy {
+					t.Errorf("collision %+v: want policy %s, earlier run %v", c, tt.policy, tt.earlierRun)
+				}
+				if tt.policy == CollisionSuffix && c.WrittenAs == "" {
+					t.Errorf("collision %+v: no written_as under suffix", c)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package pdfextract
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Pixels per PDF point in debug overlays.
+const overlayScale = 2.0
+
+// Colours of the debug overlay.
+var (
+	overlayPhoto      = color.RGBA{0, 160, 0, 255}
+	overlayNoID       = color.RGBA{255, 140, 0, 255}
+	overlayHeader     = color.RGBA{128, 128, 128, 255}
+	overlayID         = color.RGBA{0, 90, 255, 255}
+	overlayNoPhoto    = color.RGBA{200, 0, 200, 255}
+	overlayPairing    = color.RGBA{220, 0, 0, 255}
+	overlayBackground = color.NRGBA{255, 255, 255, 220}
+)
+
+// overlay draws on a page image in PDF coordinates.
+type overlay struct {
+	img      *image.RGBA
+	llx, ury float64
+	scale    float64
+}
+
+// Renders a page to PNG with the outcome of pairing drawn over it: paired
+// photos in green, photos without an ID in orange, header images in grey,
+// ID text in blue (magenta without a photo) and a red line for every pair.
+// When the page cannot be rendered, its images are drawn on a blank page.
+func renderOverlay(doc *docSession, pageNum int, mediaBox ManifestBox, pairs pairResult, headers []photoMark, missing []string) ([]byte, error) {
+	if mediaBox.Width <= 0 || mediaBox.Height <= 0 {
+		return nil, fmt.Errorf("page %d has an empty media box", pageNum)
+	}
+	o := &overlay{
+		img:   image.NewRGBA(image.Rect(0, 0, int(mediaBox.Width*overlayScale), int(mediaBox.Height*overlayScale))),
+		llx:   mediaBox.X,
+		ury:   mediaBox.Y + mediaBox.Height,
+		scale: overlayScale,
+	}
+
+	if rendered, err := doc.renderPage(pageNum, o.img.Rect.Dx()); err == nil {
+		xdraw.ApproxBiLinear.Scale(o.img, o.img.Rect, rendered, rendered.Bounds(), xdraw.Src, nil)
+	} else {
+		doc.log.Info("Could not render for the debug overlay, drawing its images only", "page", pageNum, "error", err)
+		xdraw.Draw(o.img, o.img.Rect, image.White, image.Point{}, xdraw.Src)
+		marks := append(append([]photoMark{}, headers...), pairs.UnpairedPhotos...)
+		for _, p := range pairs.Pairs {
+			marks = append(marks, p.Photo)
+		}
+		for _, m := range marks {
+			gimg := m.Image.Image
+			xdraw.ApproxBiLinear.Scale(o.img, o.rect(m.Box), gimg, gimg.Bounds(), xdraw.Over, nil)
+		}
+	}
+
+	for _, h := range headers {
+		o.box(h.Box, overlayHeader)
+		o.label(h.Box, fmt.Sprintf("#%d header", h.Index), overlayHeader)
+	}
+	for _, p := range pairs.UnpairedPhotos {
+		o.box(p.Box, overlayNoID)
+		o.label(p.Box, fmt.Sprintf("#%d no ID", p.Index), overlayNoID)
+	}
+	for _, id := range pairs.UnpairedIDs {
+		o.box(id.Box, overlayNoPhoto)
+		o.label(id.Box, "no photo", overlayNoPhoto)
+	}
+	for _, p := range pairs.Pairs {
+		o.box(p.Photo.Box, overlayPhoto)
+		o.label(p.Photo.Box, fmt.Sprintf("#%d", p.Photo.Index), overlayPhoto)
+		o.box(p.ID.Box, overlayID)
+		px, py := p.Photo.Box.center()
+		ix, iy := p.ID.Box.center()
+		o.line(px, py, ix, iy, overlayPairing)
+	}
+
+	type legendLine struct {
+		text string
+		c    color.Color
+	}
+	legend := []legendLine{
+		{fmt.Sprintf("page %d: %d pair(s)", pageNum, len(pairs.Pairs)), color.Black},
+		{"photo paired with an ID", overlayPhoto},
+		{"ID text", overlayID},
+		{"pairing", overlayPairing},
+		{"photo without an ID", overlayNoID},
+		{"ID without a photo", overlayNoPhoto},
+		{"skipped header image", overlayHeader},
+	}
+	if len(missing) > 0 {
+		legend = append(legend, legendLine{"not found in text marks: " + strings.Join(missing, ", "), overlayNoPhoto})
+	}
+	lineHeight := basicfont.Face7x13.Height
+	longest := 0
+	for _, l := range legend {
+		longest = max(longest, len(l.text))
+	}
+	// In the bottom left corner, away from the header images
+	top := o.img.Rect.Dy() - 8 - len(legend)*lineHeight
+	xdraw.Draw(o.img, image.Rect(0, top, 16+longest*basicfont.Face7x13.Advance, o.img.Rect.Dy()),
+		image.NewUniform(overlayBackground), image.Point{}, xdraw.Over)
+	for i, l := range legend {
+		o.text(8, top+4+(i+1)*lineHeight-3, l.text, l.c)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, o.img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// point converts PDF coordinates to pixels.
+func (o *overlay) point(x, y float64) (int, int) {
+	return int(math.Round((x - o.llx) * o.scale)), int(math.Round((o.ury - y) * o.scale))
+}
+
+func (o *overlay) rect(b box) image.Rectangle {
+	x0, y0 := o.point(b.X, b.Y+b.H)
+	x1, y1 := o.point(b.X+b.W, b.Y)
+	return image.Rect(x0, y0, x1, y1)
+}
+
+func (o *overlay) box(b box, c color.Color) {
+	r := o.rect(b)
+	for t := 0; t < 2; t++ {
+		for x := r.Min.X - t; x <= r.Max.X+t; x++ {
+			o.img.Set(x, r.Min.Y-t, c)
+			o.img.Set(x, r.Max.Y+t, c)
+		}
+		for y := r.Min.Y - t; y <= r.Max.Y+t; y++ {
+			o.img.Set(r.Min.X-t, y, c)
+			o.img.Set(r.Max.X+t, y, c)
+		}
+	}
+}
+
+func (o *overlay) line(x0, y0, x1, y1 float64, c color.Color) {
+	ax, ay := o.point(x0, y0)
+	bx, by := o.point(x1, y1)
+	steps := max(abs(bx-ax), abs(by-ay), 1)
+	for i := 0; i <= steps; i++ {
+		x := ax + (bx-ax)*i/steps
+		y := ay + (by-ay)*i/steps
+		o.img.Set(x, y, c)
+		o.img.Set(x+1, y, c)
+		o.img.Set(x, y+1, c)
+	}
+}
+
+// label writes text just above the top left corner of b.
+func (o *overlay) label(b box, text string, c color.Color) {
+	r := o.rect(b)
+	o.text(r.Min.X, r.Min.Y-4, text, c)
+}
+
+func (o *overlay) text(x, y int, text string, c color.Color) {
+	d := font.Drawer{
+		Dst:  o.img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
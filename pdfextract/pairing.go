@@ -0,0 +1,180 @@
+package pdfextract
+
+import (
+	"math"
+	"regexp"
+	"sort"
+)
+
+const (
+	// How far a record cell reaches sideways from a photo, in photo widths.
+	cellWidthRatio = 3.0
+	// How far a record cell reaches above/below a photo, in photo heights.
+	cellHeightRatio = 0.25
+)
+
+// box is an axis-aligned rectangle in PDF user space (origin at the lower left).
+type box struct {
+	X, Y, W, H float64
+}
+
+func (b box) center() (float64, float64) {
+	return b.X + b.W/2, b.Y + b.H/2
+}
+
+func (b box) contains(x, y float64) bool {
+	return x >= b.X && x <= b.X+b.W && y >= b.Y && y <= b.Y+b.H
+}
+
+// photoMark is an image on the page together with its index in the page's image list.
+type photoMark struct {
+	Index int
+	Box   box
+	Image PageImage
+}
+
+// idMark is a voter ID and where its text was drawn on the page.
+type idMark struct {
+	ID  string
+	Box box
+}
+
+type pairing struct {
+	Photo    photoMark
+	ID       idMark
+	Distance float64
+}
+
+// pairResult holds the photo/ID pairs of one page and everything left over.
+type pairResult struct {
+	Pairs          []pairing
+	UnpairedPhotos []photoMark
+	UnpairedIDs    []idMark
+}
+
+func photoMarksFromImages(images []PageImage) []photoMark {
+	photos := make([]photoMark, 0, len(images))
+	for i, img := range images {
+		photos = append(photos, photoMark{
+			Index: i,
+			Box:   box{X: img.Box.X, Y: img.Box.Y, W: img.Box.Width, H: img.Box.Height},
+			Image: img,
+		})
+	}
+	return photos
+}
+
+// Locates each of the given IDs in the page text and returns its bounding box.
+// IDs that cannot be found in the text layout are returned separately. Digits
+// are matched in their ASCII form whatever script the page prints them in.
+func locateIDMarks(layout *TextLayout, ids []string, idPattern *regexp.Regexp) ([]idMark, []string) {
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	text, offsets := normalizeDigits(layout.Text)
+
+	var located []idMark
+	found := make(map[string]bool)
+	for _, loc := range idPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := idSpan(loc)
+		id := text[start:end]
+		if !wanted[id] || found[id] {
+			continue
+		}
+		bbox, ok := layout.spanBox(offsets[start], offsets[end])
+		if !ok {
+			continue
+		}
+		found[id] = true
+		located = append(located, idMark{ID: id, Box: bbox})
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	return located, missing
+}
+
+// spanBox is the bounding box of the text between two byte offsets.
+func (l *TextLayout) spanBox(from, to int) (box, bool) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, s := range l.Spans {
+		if s.Offset >= to || s.Offset+s.Len <= from {
+			continue
+		}
+		minX, minY = math.Min(minX, s.Box.X), math.Min(minY, s.Box.Y)
+		maxX, maxY = math.Max(maxX, s.Box.X+s.Box.Width), math.Max(maxY, s.Box.Y+s.Box.Height)
+	}
+	if math.IsInf(minX, 1) {
+		return box{}, false
+	}
+	return box{X: minX, Y: minY, W: maxX - minX, H: maxY - minY}, true
+}
+
+// guessRecordCell is the area around a photo that probably belongs to the same
+// voter record, for templates that do not describe a grid.
+func guessRecordCell(photo box) box {
+	dx := photo.W * cellWidthRatio
+	dy := photo.H * cellHeightRatio
+	return box{X: photo.X - dx, Y: photo.Y - dy, W: photo.W + 2*dx, H: photo.H + 2*dy}
+}
+
+// Pairs each photo with the nearest ID drawn inside its record cell. Candidate
+// pairs are assigned closest first so every photo and every ID is used at most
+// once; anything that has no candidate left is reported as unpaired.
+func pairPhotosWithIDs(photos []photoMark, ids []idMark, recordCell func(box) box) pairResult {
+	var candidates []pairing
+	for _, p := range photos {
+		cell := recordCell(p.Box)
+		px, py := p.Box.center()
+		for _, id := range ids {
+			ix, iy := id.Box.center()
+			if !cell.contains(ix, iy) {
+				continue
+			}
+			candidates = append(candidates, pairing{
+				Photo:    p,
+				ID:       id,
+				Distance: math.Hypot(px-ix, py-iy),
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Distance < candidates[j].Distance
+	})
+
+	var result pairResult
+	usedPhoto := make(map[int]bool)
+	usedID := make(map[string]bool)
+	for _, c := range candidates {
+		if usedPhoto[c.Photo.Index] || usedID[c.ID.ID] {
+			continue
+		}
+		usedPhoto[c.Photo.Index] = true
+		usedID[c.ID.ID] = true
+		result.Pairs = append(result.Pairs, c)
+	}
+
+	sort.Slice(result.Pairs, func(i, j int) bool {
+		return result.Pairs[i].Photo.Index < result.Pairs[j].Photo.Index
+	})
+
+	for _, p := range photos {
+		if !usedPhoto[p.Index] {
+			result.UnpairedPhotos = append(result.UnpairedPhotos, p)
+		}
+	}
+	for _, id := range ids {
+		if !usedID[id.ID] {
+			result.UnpairedIDs = append(result.UnpairedIDs, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package pdfextract
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPairPhotosWithIDs(t *testing.T) {
+	photo := func(index int, x, y float64) photoMark {
+		return photoMark{Index: index, Box: box{X: x, Y: y, W: 60, H: 75}}
+	}
+	id := func(s string, x, y float64) idMark {
+		return idMark{ID: s, Box: box{X: x, Y: y, W: 40, H: 10}}
+	}
+
+	tests := []struct {
+		name           string
+		photos         []photoMark
+		ids            []idMark
+		pairs          map[int]string
+		unpairedPhotos []int
+		unpairedIDs    []string
+	}{
+		{
+			name:   "one record per row",
+			photos: []photoMark{photo(0, 300, 700), photo(1, 300, 500)},
+			ids:    []idMark{id("10000001", 100, 730), id("10000002", 100, 530)},
+			pairs:  map[int]string{0: "10000001", 1: "10000002"},
+		},
+		{
+			name:   "nearest ID wins when two share a cell",
+			photos: []photoMark{photo(0, 300, 700)},
+			ids:    []idMark{id("10000001", 150, 730), id("10000002", 200, 730)},
+			pairs:  map[int]string{0: "10000002"},
+			// The farther ID has no photo left
+			unpairedIDs: []string{"10000001"},
+		},
+		{
+			name:           "ID outside the record cell",
+			photos:         []photoMark{photo(0, 300, 700)},
+			ids:            []idMark{id("10000001", 100, 400)},
+			pairs:          map[int]string{},
+			unpairedPhotos: []int{0},
+			unpairedIDs:    []string{"10000001"},
+		},
+		{
+			name:   "every ID and photo used once",
+			photos: []photoMark{photo(0, 300, 700), photo(1, 300, 690)},
+			ids:    []idMark{id("10000001", 250, 720)},
+			pairs:  map[int]string{1: "10000001"},
+			// Photo 1 is closer to the ID, photo 0 is left over
+			unpairedPhotos: []int{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pairPhotosWithIDs(tt.photos, tt.ids, guessRecordCell)
+
+			pairs := make(map[int]string)
+			for _, p := range got.Pairs {
+				pairs[p.Photo.Index] = p.ID.ID
+			}
+			if !reflect.DeepEqual(pairs, tt.pairs) {
+				t.Errorf("pairs = %v, want %v", pairs, tt.pairs)
+			}
+			var photos []int
+			for _, p := range got.UnpairedPhotos {
+				photos = append(photos, p.Index)
+			}
+			if !reflect.DeepEqual(photos, tt.unpairedPhotos) {
+				t.Errorf("unpaired photos = %v, want %v", photos, tt.unpairedPhotos)
+			}
+			var ids []string
+			for _, id := range got.UnpairedIDs {
+				ids = append(ids, id.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.unpairedIDs) {
+				t.Errorf("unpaired IDs = %v, want %v", ids, tt.unpairedIDs)
+			}
+		})
+	}
+}
+
+func TestLocateIDMarks(t *testing.T) {
+	// "मतदाता नं: " then the ID in Devanagari digits, one span per word
+	label := "मतदाता नं: "
+	devanagariID := "१२३४५६७८"
+	layout := &TextLayout{
+		Text: label + devanagariID + " 87654321",
+		Spans: []TextSpan{
+			{Offset: 0, Len: len(label), Box: ManifestBox{X: 10, Y: 700, Width: 80, Height: 10}},
+			{Offset: len(label), Len: len(devanagariID), Box: ManifestBox{X: 95, Y: 700, Width: 50, Height: 10}},
+			{Offset: len(label) + len(devanagariID) + 1, Len: 8, Box: ManifestBox{X: 95, Y: 500, Width: 50, Height: 10}},
+		},
+	}
+
+	marks, missing := locateIDMarks(layout, []string{"12345678", "87654321", "11111111"}, DefaultTemplate().ids.pattern)
+
+	want := []idMark{
+		// Matched in ASCII, boxed by the span of the Devanagari digits
+		{ID: "12345678", Box: box{X: 95, Y: 700, W: 50, H: 10}},
+		{ID: "87654321", Box: box{X: 95, Y: 500, W: 50, H: 10}},
+	}
+	if !reflect.DeepEqual(marks, want) {
+		t.Errorf("marks = %+v, want %+v", marks, want)
+	}
+	if !reflect.DeepEqual(missing, []string{"11111111"}) {
+		t.Errorf("missing = %v, want [11111111]", missing)
+	}
+}
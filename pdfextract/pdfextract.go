@@ -0,0 +1,208 @@
+// Package pdfextract extracts voter photos from Nepali voter roll PDFs and
+// names each photo after the voter ID printed next to it. It can also parse
+// the voter records of every page.
+//
+// Text is read with ledongthuc/pdf or unipdf and images with unipdf or the
+// package's own decoder, see Backend, TextSource and ImageSource. Unless the
+// OfflineBackend is used, the caller has to set a unipdf license (see
+// github.com/unidoc/unipdf/v4/common/license) before running an Extractor.
+// Progress is logged through log/slog, see Options.Logger.
+package pdfextract
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+)
+
+// Options controls what is extracted and written for each input file. The
+// zero value of every field selects its default.
+type Options struct {
+	// OutputDir is where photos, manifests and records are written. When it
+	// is empty nothing is written to disk and results are only delivered
+	// through OnPhoto and OnRecord.
+	OutputDir string
+	// OutputLayout is the folder hierarchy under OutputDir for each file's
+	// photos, built from its roll metadata. Defaults to "{file}". Without
+	// {file}, several files can share a folder, and each file's manifest and
+	// records are named after it, e.g. <file>.manifest.json.
+	OutputLayout string
+	// Records also writes the parsed voter records as CSV and JSON Lines.
+	Records bool
+	// Template describes the page layout of the roll. Defaults to DefaultTemplate().
+	Template *Template
+	// Format and JPEGQuality control how photos are written. Defaults to
+	// FormatJPEG at quality 90.
+	Format      Format
+	JPEGQuality int
+	// DebugOverlay also draws every page with images to debug/page-NNN.png
+	// in the file's output folder, showing the image and ID marks, how they
+	// were paired and which header images were skipped. Pages restored from
+	// a checkpoint are not drawn again.
+	DebugOverlay bool
+	// PhotoHash also computes a perceptual hash of every photo, see
+	// ManifestEntry.PhotoHash and PhotoHashDistance.
+	PhotoHash bool
+
+	// Backend says which library reads the text, text layout and images.
+	// Defaults to DefaultBackend.
+	Backend Backend
+
+	// Workers is the number of pages processed in parallel across all
+	// files. Defaults to the number of CPUs.
+	Workers int
+	// MaxMemory is the approximate memory, in bytes, open documents may use.
+	// Defaults to 2 GB. Ignored when Memory is set.
+	MaxMemory int64
+	// Memory, when set, is a budget shared with other extractors.
+	Memory *MemoryBudget
+	// OnError says what happens after a failure. Defaults to SkipPage.
+	OnError ErrorPolicy
+	// OnCollision says what happens when two photos of a run get the same
+	// output file, or a photo would replace a different file left by an
+	// earlier run. Defaults to CollisionOverwrite; every collision is
+	// recorded in the report either way.
+	OnCollision CollisionPolicy
+
+	// StateDir holds checkpoints so a later run can resume where this one
+	// stopped. Defaults to .checkpoints in OutputDir; without either no
+	// checkpoints are kept.
+	StateDir string
+	// Force reprocesses everything, ignoring checkpoints.
+	Force bool
+
+	// OnPhoto, OnRecord and OnProgress are called as pages are finished,
+	// in page order within each file. They are called from the extraction
+	// goroutines and should return quickly.
+	OnPhoto    func(ExtractedPhoto)
+	OnRecord   func(VoterRecord)
+	OnProgress func(Progress)
+
+	// Logger receives what the extraction does and runs into, with file,
+	// page and image_index attributes where they apply. Defaults to
+	// slog.Default().
+	Logger *slog.Logger
+}
+
+// ExtractedPhoto is one photo as it is written out. Data is nil for photos
+// restored from the checkpoint of an earlier run, which are already on disk.
+type ExtractedPhoto struct {
+	ManifestEntry
+	Data []byte
+	// Path is the file the photo was written to, empty without OutputDir.
+	Path string
+}
+
+// Progress is how far the extraction of one input file has got.
+type Progress struct {
+	Path      string
+	Pages     int
+	PagesDone int
+	Photos    int
+	// Done is set once the file's manifest and records are written.
+	Done bool
+}
+
+// Extractor runs extractions with one set of options. Several runs may use
+// the same Extractor at once; they share its memory budget.
+type Extractor struct {
+	opts   Options
+	budget *MemoryBudget
+}
+
+// New checks the options and fills in their defaults.
+func New(opts Options) (*Extractor, error) {
+	if opts.Template == nil {
+		opts.Template = DefaultTemplate()
+	}
+	if opts.Format == "" {
+		opts.Format = FormatJPEG
+	}
+	if _, err := ParseFormat(string(opts.Format)); err != nil {
+		return nil, err
+	}
+	if opts.JPEGQuality == 0 {
+		opts.JPEGQuality = 90
+	}
+	if opts.JPEGQuality < 1 || opts.JPEGQuality > 100 {
+		return nil, fmt.Errorf("JPEG quality must be between 1 and 100, got %d", opts.JPEGQuality)
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.Backend == (Backend{}) {
+		opts.Backend = DefaultBackend
+	}
+	if err := opts.Backend.validate(); err != nil {
+		return nil, err
+	}
+	if opts.OutputLayout == "" {
+		opts.OutputLayout = DefaultOutputLayout
+	}
+	if err := ValidateOutputLayout(opts.OutputLayout); err != nil {
+		return nil, err
+	}
+	if opts.Workers < 1 {
+		opts.Workers = runtime.NumCPU()
+	}
+	if opts.OnError == "" {
+		opts.OnError = SkipPage
+	}
+	if _, err := ParseErrorPolicy(string(opts.OnError)); err != nil {
+		return nil, err
+	}
+	if opts.OnCollision == "" {
+		opts.OnCollision = CollisionOverwrite
+	}
+	if _, err := ParseCollisionPolicy(string(opts.OnCollision)); err != nil {
+		return nil, err
+	}
+	if opts.StateDir == "" && opts.OutputDir != "" {
+		opts.StateDir = filepath.Join(opts.OutputDir, ".checkpoints")
+	}
+
+	budget := opts.Memory
+	if budget == nil {
+		if opts.MaxMemory <= 0 {
+			opts.MaxMemory = 2048 << 20
+		}
+		budget = NewMemoryBudget(opts.MaxMemory)
+	}
+	return &Extractor{opts: opts, budget: budget}, nil
+}
+
+// Options returns the options in use, defaults filled in.
+func (e *Extractor) Options() Options {
+	return e.opts
+}
+
+// Run extracts every file. Failures are recorded in report and handled by
+// the error policy; Run only returns an error when ctx is cancelled. A photo
+// being written is then always finished, pages not yet written are left for
+// the next run, and the manifest and records of every started file are
+// written for the pages done so far.
+func (e *Extractor) Run(ctx context.Context, files []string, report *Report) error {
+	if report == nil {
+		report = NewReport(e.opts.OnError)
+	}
+	if report.Logger == nil {
+		report.Logger = e.opts.Logger
+	}
+	b := &batch{
+		ctx:     ctx,
+		opts:    e.opts,
+		report:  report,
+		budget:  e.budget,
+		outputs: newOutputRegistry(),
+	}
+	runBatch(files, b)
+	if err := ctx.Err(); err != nil {
+		report.mu.Lock()
+		report.Interrupted = true
+		report.mu.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package pdfextract
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math/bits"
+	"strconv"
+)
+
+// Perceptual photo hashes are 64-bit difference hashes: the photo is
+// shrunk to 9x8 grey cells and every bit says whether a cell is brighter
+// than its right neighbour. Re-encoding, rescaling and small retouches
+// change few bits, so near-identical photos have a small Hamming distance.
+const (
+	hashCols = 9
+	hashRows = 8
+)
+
+func photoHash(img image.Image) uint64 {
+	b := img.Bounds()
+	if b.Dx() < 1 || b.Dy() < 1 {
+		return 0
+	}
+
+	var grey [hashRows][hashCols]float64
+	for row := 0; row < hashRows; row++ {
+		y0, y1 := cellSpan(b.Min.Y, b.Dy(), row, hashRows)
+		for col := 0; col < hashCols; col++ {
+			x0, x1 := cellSpan(b.Min.X, b.Dx(), col, hashCols)
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum += float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+				}
+			}
+			grey[row][col] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+
+	var h uint64
+	for row := 0; row < hashRows; row++ {
+		for col := 0; col < hashCols-1; col++ {
+			h <<= 1
+			if grey[row][col] > grey[row][col+1] {
+				h |= 1
+			}
+		}
+	}
+	return h
+}
+
+// cellSpan is the pixel range of cell i out of n along a side, never empty.
+func cellSpan(min, size, i, n int) (int, int) {
+	from := min + i*size/n
+	to := min + (i+1)*size/n
+	if to <= from {
+		to = from + 1
+	}
+	if to > min+size {
+		from, to = min+size-1, min+size
+	}
+	return from, to
+}
+
+func formatPhotoHash(h uint64) string {
+	return fmt.Sprintf("%016x", h)
+}
+
+func parsePhotoHash(s string) (uint64, error) {
+	h, err := strconv.ParseUint(s, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid photo hash %q: %v", s, err)
+	}
+	return h, nil
+}
+
+// PhotoHashDistance is the number of differing bits between two photo
+// hashes from ManifestEntry.PhotoHash. Up to about 6 of 64 means the same
+// photo.
+func PhotoHashDistance(a, b string) (int, error) {
+	ha, err := parsePhotoHash(a)
+	if err != nil {
+		return 0, err
+	}
+	hb, err := parsePhotoHash(b)
+	if err != nil {
+		return 0, err
+	}
+	return bits.OnesCount64(ha ^ hb), nil
+}
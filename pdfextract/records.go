@@ -0,0 +1,199 @@
+package pdfextract
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VoterRecord is one voter's entry in the roll.
+type VoterRecord struct {
+	SourceFile   string `json:"source_file"`
+	SerialNumber string `json:"serial_number"`
+	VoterID      string `json:"voter_id"`
+	Name         string `json:"name"`
+	Age          int    `json:"age,omitempty"`
+	Gender       string `json:"gender"`
+	ParentName   string `json:"parent_name"`
+	SpouseName   string `json:"spouse_name"`
+	Page         int    `json:"page"`
+	PhotoFile    string `json:"photo_file"`
+	// Roll is set for every record of a file when it is written.
+	Roll *RollMetadata `json:"roll,omitempty"`
+}
+
+var recordCSVHeader = append([]string{
+	"source_file", "serial_number", "voter_id", "name", "age", "gender", "parent_name", "spouse_name", "page", "photo_file",
+}, rollCSVHeader...)
+
+type recordField int
+
+const (
+	fieldSerial recordField = iota
+	fieldVoterID
+	fieldName
+	fieldAge
+	fieldGender
+	fieldParent
+	fieldSpouse
+)
+
+// Labels printed in front of each value of a record block.
+var recordLabels = []struct {
+	field   recordField
+	pattern *regexp.Regexp
+}{
+	{fieldSerial, regexp.MustCompile(`क\.\s*सं?\.?`)},
+	{fieldVoterID, regexp.MustCompile(`मतदाता\s*(?:नं|नम्बर)\.?`)},
+	{fieldName, regexp.MustCompile(`मतदाताको\s*नाम`)},
+	{fieldAge, regexp.MustCompile(`उमेर\s*(?:\(वर्ष\))?`)},
+	{fieldGender, regexp.MustCompile(`लि(?:ङ्ग|ंग)`)},
+	{fieldParent, regexp.MustCompile(`(?:पिता|बाबु)\s*/\s*(?:माता|आमा)को\s*नाम`)},
+	{fieldSpouse, regexp.MustCompile(`पति\s*/\s*पत्नीको\s*नाम`)},
+}
+
+var (
+	recordStartPattern = recordLabels[0].pattern
+	leadingDigits      = regexp.MustCompile(`\d+`)
+)
+
+type labelMatch struct {
+	field      recordField
+	start, end int
+}
+
+// Splits the page text into record blocks, each starting at a serial number
+// label, and reads the labelled values out of every block.
+func parseVoterRecords(pageText string, pageNum int, ids idMatcher, logger *slog.Logger) []VoterRecord {
+	cleaned := strings.ReplaceAll(pageText, "\uFFFD", "")
+
+	starts := recordStartPattern.FindAllStringIndex(cleaned, -1)
+	records := make([]VoterRecord, 0, len(starts))
+	for i, loc := range starts {
+		end := len(cleaned)
+		if i+1 < len(starts) {
+			end = starts[i+1][0]
+		}
+		rec := parseRecordBlock(cleaned[loc[0]:end], ids, logger)
+		if rec.SerialNumber == "" && rec.VoterID == "" {
+			continue
+		}
+		rec.Page = pageNum
+		records = append(records, rec)
+	}
+	return records
+}
+
+func parseRecordBlock(block string, ids idMatcher, logger *slog.Logger) VoterRecord {
+	var matches []labelMatch
+	for _, l := range recordLabels {
+		for _, loc := range l.pattern.FindAllStringIndex(block, -1) {
+			matches = append(matches, labelMatch{field: l.field, start: loc[0], end: loc[1]})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+
+	var rec VoterRecord
+	for i, m := range matches {
+		end := len(block)
+		if i+1 < len(matches) {
+			end = matches[i+1].start
+		}
+		value := cleanFieldValue(block[m.end:end])
+
+		switch m.field {
+		case fieldSerial:
+			rec.SerialNumber = leadingDigits.FindString(value)
+		case fieldVoterID:
+			rec.VoterID = leadingDigits.FindString(value)
+		case fieldName:
+			rec.Name = value
+		case fieldAge:
+			rec.Age, _ = strconv.Atoi(leadingDigits.FindString(value))
+		case fieldGender:
+			rec.Gender = value
+		case fieldParent:
+			rec.ParentName = value
+		case fieldSpouse:
+			rec.SpouseName = value
+		}
+	}
+
+	if rec.VoterID != "" && !ids.accept(rec.VoterID, logger) {
+		rec.VoterID = ""
+	}
+	// Some rolls print the ID without a label
+	if rec.VoterID == "" {
+		if found := extractVoterIDs(block, ids, logger); len(found) > 0 {
+			rec.VoterID = found[0]
+		}
+	}
+	return rec
+}
+
+func cleanFieldValue(s string) string {
+	s = strings.TrimLeft(s, " \t\n:ः-")
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Writes records.csv and records.jsonl into dir, their names preceded by prefix.
+func writeVoterRecords(dir, prefix string, records []VoterRecord) error {
+	if err := writeRecordsCSV(filepath.Join(dir, prefix+"records.csv"), records); err != nil {
+		return err
+	}
+	return writeRecordsJSONL(filepath.Join(dir, prefix+"records.jsonl"), records)
+}
+
+func writeRecordsCSV(path string, records []VoterRecord) error {
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	w := csv.NewWriter(f)
+	if err := w.Write(recordCSVHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		age := ""
+		if r.Age > 0 {
+			age = strconv.Itoa(r.Age)
+		}
+		row := []string{
+			r.SourceFile, r.SerialNumber, r.VoterID, r.Name, age, r.Gender, r.ParentName, r.SpouseName,
+			strconv.Itoa(r.Page), r.PhotoFile,
+		}
+		row = append(row, r.Roll.csvFields()...)
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Commit()
+}
+
+func writeRecordsJSONL(path string, records []VoterRecord) error {
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	defer f.Abort()
+
+	enc := json.NewEncoder(f)
+	enc.SetEscapeHTML(false)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return f.Commit()
+}
@@ -0,0 +1,59 @@
+package pdfextract
+
+import (
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+func TestParseVoterRecords(t *testing.T) {
+	logger := slog.New(slog.DiscardHandler)
+	tests := []struct {
+		name string
+		text string
+		want []VoterRecord
+	}{
+		{
+			name: "every field",
+			text: "क.सं. १ मतदाता नं: ५०१२३४५६ मतदाताको नाम: राम बहादुर थापा उमेर(वर्ष): ४५ लिङ्ग: पुरुष\n" +
+				"पिता/माताको नाम: हरि थापा पति/पत्नीको नाम: सीता थापा\n",
+			want: []VoterRecord{{
+				SerialNumber: "1", VoterID: "50123456", Name: "राम बहादुर थापा", Age: 45, Gender: "पुरुष",
+				ParentName: "हरि थापा", SpouseName: "सीता थापा", Page: 3,
+			}},
+		},
+		{
+			name: "one block per serial number",
+			text: "क.सं. १ मतदाता नं: ५०१२३४५६ मतदाताको नाम: राम थापा उमेर: ४५\n" +
+				"क.सं. २ मतदाता नं: ५०१२३४५७ मतदाताको नाम: सीता थापा उमेर: ४१ लिंग: महिला\n",
+			want: []VoterRecord{
+				{SerialNumber: "1", VoterID: "50123456", Name: "राम थापा", Age: 45, Page: 3},
+				{SerialNumber: "2", VoterID: "50123457", Name: "सीता थापा", Age: 41, Gender: "महिला", Page: 3},
+			},
+		},
+		{
+			name: "ID printed without a label",
+			text: "क.सं. ३\n50123458\nमतदाताको नाम: गोपाल\n",
+			want: []VoterRecord{{SerialNumber: "3", VoterID: "50123458", Name: "गोपाल", Page: 3}},
+		},
+		{
+			name: "labelled value rejected by the ID rules",
+			// A BS year is no voter ID, and nothing else in the block is one
+			text: "क.सं. ४ मतदाता नं: २०८० मतदाताको नाम: कृष्ण\n",
+			want: []VoterRecord{{SerialNumber: "4", Name: "कृष्ण", Page: 3}},
+		},
+		{
+			name: "no record labels",
+			text: "मतदाता नामावली वडा नं. ३\n",
+			want: []VoterRecord{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseVoterRecords(normalizeText(tt.text), 3, DefaultTemplate().ids, logger)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package pdfextract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrorPolicy says what happens to the rest of the batch after a failure.
+type ErrorPolicy string
+
+const (
+	SkipPage ErrorPolicy = "skip-page"
+	SkipFile ErrorPolicy = "skip-file"
+	AbortRun ErrorPolicy = "abort"
+)
+
+// ParseErrorPolicy parses skip-page, skip-file or abort.
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	switch p := ErrorPolicy(s); p {
+	case SkipPage, SkipFile, AbortRun:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown error policy %q (want skip-page, skip-file or abort)", s)
+}
+
+// Error categories used in the run report.
+const (
+	CategoryInput  = "input"
+	CategoryOpen   = "open"
+	CategoryPage   = "page"
+	CategoryText   = "text"
+	CategoryImage  = "image"
+	CategoryEncode = "encode"
+	CategoryWrite  = "write"
+	// Another photo of the run already has the output file.
+	CategoryCollision = "collision"
+	CategoryOther     = "other"
+)
+
+type categorizedError struct {
+	category string
+	err      error
+}
+
+func (e *categorizedError) Error() string { return e.err.Error() }
+func (e *categorizedError) Unwrap() error { return e.err }
+
+// WithCategory tags err with the report category it should be listed under.
+func WithCategory(category string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &categorizedError{category: category, err: err}
+}
+
+func errorCategory(err error) string {
+	var ce *categorizedError
+	if errors.As(err, &ce) {
+		return ce.category
+	}
+	return CategoryOther
+}
+
+// Failure is one failed file, or one failed page of a file.
+type Failure struct {
+	File     string `json:"file"`
+	Page     int    `json:"page,omitempty"`
+	Category string `json:"category"`
+	Error    string `json:"error"`
+}
+
+// SkippedInput is an input left out of the run because it is not a PDF file.
+type SkippedInput struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// Report is the machine-readable summary written at the end of a run.
+type Report struct {
+	mu sync.Mutex
+
+	Started      time.Time   `json:"started"`
+	Finished     time.Time   `json:"finished"`
+	Policy       ErrorPolicy `json:"policy"`
+	Files        int         `json:"files"`
+	FailedFiles  int         `json:"failed_files"`
+	SkippedFiles int         `json:"skipped_files"`
+	// Files whose output was written in full, and pages written, by this run.
+	CompletedFiles int       `json:"completed_files"`
+	PagesWritten   int       `json:"pages_written"`
+	Interrupted    bool      `json:"interrupted,omitempty"`
+	Failures       []Failure `json:"failures"`
+	// Photos that got an output file already taken in the run.
+	Collisions []Collision `json:"collisions"`
+	// Inputs that were not PDF files; they are not failures.
+	SkippedInputs []SkippedInput `json:"skipped_inputs"`
+
+	// OnFailure, when set, is called for every failure as it is recorded.
+	OnFailure func(Failure) `json:"-"`
+	// Logger logs every failure as it is recorded. Defaults to the
+	// extractor's logger, or slog.Default() outside of a run.
+	Logger *slog.Logger `json:"-"`
+
+	failedFiles map[string]bool
+}
+
+// NewReport starts an empty report for a run under policy.
+func NewReport(policy ErrorPolicy) *Report {
+	return &Report{
+		Started:       time.Now(),
+		Policy:        policy,
+		Failures:      []Failure{},
+		Collisions:    []Collision{},
+		SkippedInputs: []SkippedInput{},
+		failedFiles:   make(map[string]bool),
+	}
+}
+
+// AddFailure records a failure of a whole file (page 0) or of one page.
+func (r *Report) AddFailure(file string, page int, err error) {
+	logger := r.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := []any{"file", file}
+	if page > 0 {
+		attrs = append(attrs, "page", page)
+	}
+	logger.Error("Extraction failed", append(attrs, "category", errorCategory(err), "error", err)...)
+
+	f := Failure{
+		File:     file,
+		Page:     page,
+		Category: errorCategory(err),
+		Error:    err.Error(),
+	}
+	r.mu.Lock()
+	r.Failures = append(r.Failures, f)
+	if !r.failedFiles[file] {
+		r.failedFiles[file] = true
+		r.FailedFiles++
+	}
+	r.mu.Unlock()
+	if r.OnFailure != nil {
+		r.OnFailure(f)
+	}
+}
+
+// AddSkippedInput records an input that is left out without counting as a
+// failure, such as a file that is not a PDF.
+func (r *Report) AddSkippedInput(file, reason string) {
+	logger := r.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Warn("Skipping input", "file", file, "reason", reason)
+
+	r.mu.Lock()
+	r.SkippedInputs = append(r.SkippedInputs, SkippedInput{File: file, Reason: reason})
+	r.mu.Unlock()
+}
+
+// addSkipped counts a file that an earlier run already completed.
+func (r *Report) addSkipped() {
+	r.mu.Lock()
+	r.SkippedFiles++
+	r.mu.Unlock()
+}
+
+func (r *Report) addCollision(c Collision) {
+	r.mu.Lock()
+	r.Collisions = append(r.Collisions, c)
+	r.mu.Unlock()
+}
+
+func (r *Report) addCompleted() {
+	r.mu.Lock()
+	r.CompletedFiles++
+	r.mu.Unlock()
+}
+
+func (r *Report) addPage() {
+	r.mu.Lock()
+	r.PagesWritten++
+	r.mu.Unlock()
+}
+
+// FailureCount is the number of failures recorded so far.
+func (r *Report) FailureCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.Failures)
+}
+
+// FailureList returns a copy of the failures recorded so far.
+func (r *Report) FailureList() []Failure {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Failure{}, r.Failures...)
+}
+
+// Write saves the report as JSON.
+func (r *Report) Write(path string) error {
+	r.mu.Lock()
+	r.Finished = time.Now()
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, append(data, '\n'))
+}
@@ -0,0 +1,106 @@
+package pdfextract
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestRoll writes a voter roll PDF in the default layout: a cover page,
+// then pages with three header logos and a 3x4 grid of records, each with
+// its voter ID and a JPEG photo to the right of it. It needs no license to
+// write or to read back with the offline backend.
+func writeTestRoll(tb testing.TB, pages int) string {
+	tb.Helper()
+	var objs [][]byte
+	add := func(obj string, stream []byte) int {
+		if stream != nil {
+			obj = fmt.Sprintf("%s\nstream\n%s\nendstream", obj, stream)
+		}
+		objs = append(objs, []byte(obj))
+		return len(objs)
+	}
+	jpegImage := func(w, h int, c color.RGBA) int {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			tb.Fatal(err)
+		}
+		return add(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB"+
+			" /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>", w, h, buf.Len()), buf.Bytes())
+	}
+
+	font := add("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>", nil)
+	type page struct {
+		contents int
+		xobjects []string
+	}
+	var pageObjs []page
+	for p := 1; p <= pages; p++ {
+		var ops, xobjects []string
+		if p == 1 {
+			ops = append(ops, "BT /F1 12 Tf 50 750 Td (Ward 3 Polling Centre: Shree School) Tj ET")
+		} else {
+			for i := 0; i < 3; i++ {
+				xobjects = append(xobjects, fmt.Sprintf("/L%d %d 0 R", i, jpegImage(20, 20, color.RGBA{0, 0, 200, 255})))
+				ops = append(ops, fmt.Sprintf("q 20 0 0 20 %d 780 cm /L%d Do Q", 50+i*40, i))
+			}
+			for n := 0; n < 12; n++ {
+				x, y := 30+n%3*190, 80+n/3*170
+				lines := []string{
+					fmt.Sprintf("S.N. %d", n+1),
+					fmt.Sprintf("%d", 10000000+p*100+n),
+					fmt.Sprintf("Name: Voter %d", n),
+					fmt.Sprintf("Age: %d", 30+n),
+				}
+				for k, line := range lines {
+					ops = append(ops, fmt.Sprintf("BT /F1 9 Tf %d %d Td (%s) Tj ET", x, y+60-k*12, line))
+				}
+				photo := jpegImage(60, 75, color.RGBA{uint8(20 * n), 100, uint8(p * 10), 255})
+				xobjects = append(xobjects, fmt.Sprintf("/P%d %d 0 R", n, photo))
+				ops = append(ops, fmt.Sprintf("q 60 0 0 75 %d %d cm /P%d Do Q", x+50, y, n))
+			}
+		}
+		content := strings.Join(ops, "\n")
+		pageObjs = append(pageObjs, page{add(fmt.Sprintf("<< /Length %d >>", len(content)), []byte(content)), xobjects})
+	}
+	// The page tree goes right after the pages
+	pagesID := len(objs) + pages + 1
+	var kids []string
+	for _, p := range pageObjs {
+		id := add(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 612 842] /Contents %d 0 R"+
+			" /Resources << /Font << /F1 %d 0 R >> /XObject << %s >> >> >>",
+			pagesID, p.contents, font, strings.Join(p.xobjects, " ")), nil)
+		kids = append(kids, fmt.Sprintf("%d 0 R", id))
+	}
+	add(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), pages), nil)
+	catalog := add(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID), nil)
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, obj := range objs {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, catalog, xref)
+
+	path := filepath.Join(tb.TempDir(), "roll.pdf")
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		tb.Fatal(err)
+	}
+	return path
+}
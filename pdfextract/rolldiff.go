@@ -0,0 +1,158 @@
+package pdfextract
+
+import (
+	"sort"
+	"strconv"
+)
+
+// RollDiff is what changed between two editions of a voter roll, matched
+// by voter ID.
+type RollDiff struct {
+	OldIDs  int            `json:"old_ids"`
+	NewIDs  int            `json:"new_ids"`
+	Added   []Occurrence   `json:"added"`
+	Removed []Occurrence   `json:"removed"`
+	Changed []ChangedVoter `json:"changed"`
+	// MaxDistance is the largest photo hash distance still counted as the same photo.
+	MaxDistance int `json:"max_distance"`
+	// AgeGap is how many years most voters aged between the editions; an
+	// age only counts as changed when it moved by another amount.
+	AgeGap int `json:"age_gap"`
+}
+
+// ChangedVoter is a voter in both editions whose photo or record changed.
+type ChangedVoter struct {
+	VoterID string     `json:"voter_id"`
+	Old     Occurrence `json:"old"`
+	New     Occurrence `json:"new"`
+	// PhotoDistance is how many bits the photo hashes differ by, -1 when
+	// either edition has no hashed photo for the voter.
+	PhotoDistance int           `json:"photo_distance"`
+	PhotoChanged  bool          `json:"photo_changed"`
+	Fields        []FieldChange `json:"fields"`
+}
+
+// FieldChange is one record field with a different value in the new edition.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// DiffRolls compares the voter IDs indexed from an old and a new edition.
+// Photos count as changed when their hashes are more than maxDistance bits
+// apart; record fields are compared when both editions have a record. Ages
+// are expected to rise by the years between the editions, taken as the
+// most common rise (see RollDiff.AgeGap), and count as changed when they
+// are off by more than a year. An ID found more than once in an edition is
+// compared by its first occurrence.
+func DiffRolls(old, new []Occurrence, maxDistance int) RollDiff {
+	oldByID, oldIDs := firstOccurrences(old)
+	newByID, newIDs := firstOccurrences(new)
+	diff := RollDiff{
+		OldIDs:      len(oldIDs),
+		NewIDs:      len(newIDs),
+		Added:       []Occurrence{},
+		Removed:     []Occurrence{},
+		Changed:     []ChangedVoter{},
+		MaxDistance: maxDistance,
+		AgeGap:      ageGap(oldByID, newByID),
+	}
+
+	for _, id := range oldIDs {
+		if _, ok := newByID[id]; !ok {
+			diff.Removed = append(diff.Removed, oldByID[id])
+		}
+	}
+	for _, id := range newIDs {
+		o, ok := oldByID[id]
+		if !ok {
+			diff.Added = append(diff.Added, newByID[id])
+			continue
+		}
+		n := newByID[id]
+		c := ChangedVoter{VoterID: id, Old: o, New: n, PhotoDistance: -1, Fields: changedFields(o, n, diff.AgeGap)}
+		if o.PhotoHash != "" && n.PhotoHash != "" {
+			if d, err := PhotoHashDistance(o.PhotoHash, n.PhotoHash); err == nil {
+				c.PhotoDistance = d
+				c.PhotoChanged = d > maxDistance
+			}
+		}
+		if c.PhotoChanged || len(c.Fields) > 0 {
+			diff.Changed = append(diff.Changed, c)
+		}
+	}
+	return diff
+}
+
+// Returns the first occurrence of every ID, and the IDs in sorted order.
+// Records without a voter ID cannot be matched and are left out.
+func firstOccurrences(occurrences []Occurrence) (map[string]Occurrence, []string) {
+	byID := make(map[string]Occurrence)
+	var ids []string
+	for _, occ := range occurrences {
+		if occ.VoterID == "" {
+			continue
+		}
+		if _, ok := byID[occ.VoterID]; ok {
+			continue
+		}
+		byID[occ.VoterID] = occ
+		ids = append(ids, occ.VoterID)
+	}
+	sort.Strings(ids)
+	return byID, ids
+}
+
+// ageGap is the most common rise in age of the voters with an age in both
+// editions, the smallest one on a tie.
+func ageGap(old, new map[string]Occurrence) int {
+	counts := make(map[int]int)
+	for id, o := range old {
+		n, ok := new[id]
+		if !ok || o.Record == nil || n.Record == nil || o.Record.Age == 0 || n.Record.Age == 0 {
+			continue
+		}
+		counts[n.Record.Age-o.Record.Age]++
+	}
+	gap, best := 0, 0
+	for d, c := range counts {
+		if c > best || c == best && d < gap {
+			gap, best = d, c
+		}
+	}
+	return gap
+}
+
+func changedFields(o, n Occurrence, ageGap int) []FieldChange {
+	changes := []FieldChange{}
+	compare := func(field, a, b string) {
+		if a != b {
+			changes = append(changes, FieldChange{Field: field, Old: a, New: b})
+		}
+	}
+
+	if o.Record != nil && n.Record != nil {
+		age := func(r *VoterRecord) string {
+			if r.Age == 0 {
+				return ""
+			}
+			return strconv.Itoa(r.Age)
+		}
+		compare("name", o.Record.Name, n.Record.Name)
+		// Everyone ages between editions; only other changes are misreads
+		// or corrections
+		if o.Record.Age == 0 || n.Record.Age == 0 || abs(n.Record.Age-o.Record.Age-ageGap) > 1 {
+			compare("age", age(o.Record), age(n.Record))
+		}
+		compare("gender", o.Record.Gender, n.Record.Gender)
+		compare("parent_name", o.Record.ParentName, n.Record.ParentName)
+		compare("spouse_name", o.Record.SpouseName, n.Record.SpouseName)
+	}
+	// A voter moved to another ward or polling centre
+	if o.Roll != nil && n.Roll != nil {
+		compare("ward", o.Roll.Ward, n.Roll.Ward)
+		compare("polling_centre", o.Roll.PollingCentre, n.Roll.PollingCentre)
+	}
+	return changes
+}
@@ -0,0 +1,116 @@
+package pdfextract
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testOccurrence(id string, age int, name, hash string) Occurrence {
+	return Occurrence{VoterID: id, File: "roll.pdf", Page: 2, PhotoHash: hash,
+		Record: &VoterRecord{VoterID: id, Name: name, Age: age}}
+}
+
+func TestAgeGap(t *testing.T) {
+	byID := func(occs ...Occurrence) map[string]Occurrence {
+		m, _ := firstOccurrences(occs)
+		return m
+	}
+	tests := []struct {
+		name     string
+		old, new map[string]Occurrence
+		want     int
+	}{
+		{
+			name: "most common rise",
+			old:  byID(testOccurrence("1", 30, "", ""), testOccurrence("2", 40, "", ""), testOccurrence("3", 50, "", "")),
+			new:  byID(testOccurrence("1", 32, "", ""), testOccurrence("2", 42, "", ""), testOccurrence("3", 60, "", "")),
+			want: 2,
+		},
+		{
+			name: "smallest on a tie",
+			old:  byID(testOccurrence("1", 30, "", ""), testOccurrence("2", 40, "", "")),
+			new:  byID(testOccurrence("1", 33, "", ""), testOccurrence("2", 42, "", "")),
+			want: 2,
+		},
+		{
+			name: "ages missing or voters in one edition only",
+			old:  byID(testOccurrence("1", 0, "", ""), testOccurrence("2", 40, "", "")),
+			new:  byID(testOccurrence("1", 32, "", ""), testOccurrence("3", 42, "", "")),
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ageGap(tt.old, tt.new); got != tt.want {
+				t.Errorf("ageGap = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffRolls(t *testing.T) {
+	const (
+		hash     = "00000000000000ff"
+		nearHash = "00000000000000fe" // 1 bit away
+		farHash  = "ffffffff00000000"
+	)
+	old := []Occurrence{
+		testOccurrence("50000001", 30, "राम", hash),
+		testOccurrence("50000002", 40, "सीता", hash),
+		testOccurrence("50000003", 50, "हरि", hash),
+		testOccurrence("50000004", 60, "गोपाल", hash),
+		testOccurrence("50000005", 70, "कृष्ण", hash),
+		// Only the first occurrence of an ID is compared
+		testOccurrence("50000005", 99, "other", farHash),
+		// Records without an ID cannot be matched
+		testOccurrence("", 20, "unread", ""),
+	}
+	new := []Occurrence{
+		testOccurrence("50000002", 42, "सीता", nearHash),
+		testOccurrence("50000003", 52, "हरि प्रसाद", hash),
+		testOccurrence("50000004", 67, "गोपाल", farHash),
+		testOccurrence("50000005", 73, "कृष्ण", hash),
+		testOccurrence("50000006", 18, "नयाँ", hash),
+		testOccurrence("", 21, "unread", ""),
+	}
+
+	diff := DiffRolls(old, new, 6)
+
+	if diff.OldIDs != 5 || diff.NewIDs != 5 {
+		t.Errorf("IDs = %d old, %d new, want 5 and 5", diff.OldIDs, diff.NewIDs)
+	}
+	if diff.AgeGap != 2 {
+		t.Errorf("age gap = %d, want 2", diff.AgeGap)
+	}
+	ids := func(occs []Occurrence) []string {
+		var out []string
+		for _, o := range occs {
+			out = append(out, o.VoterID)
+		}
+		return out
+	}
+	if got := ids(diff.Added); !reflect.DeepEqual(got, []string{"50000006"}) {
+		t.Errorf("added = %v", got)
+	}
+	if got := ids(diff.Removed); !reflect.DeepEqual(got, []string{"50000001"}) {
+		t.Errorf("removed = %v", got)
+	}
+
+	type change struct {
+		photo  bool
+		fields []FieldChange
+	}
+	want := map[string]change{
+		// 50000002 aged by the gap and its photo moved by 1 bit, and 50000005
+		// aged within a year of the gap: both unchanged
+		"50000003": {fields: []FieldChange{{Field: "name", Old: "हरि", New: "हरि प्रसाद"}}},
+		"50000004": {photo: true, fields: []FieldChange{{Field: "age", Old: "60", New: "67"}}},
+	}
+	got := make(map[string]change)
+	for _, c := range diff.Changed {
+		got[c.VoterID] = change{c.PhotoChanged, c.Fields}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changed = %+v\nwant      %+v", got, want)
+	}
+}
@@ -0,0 +1,391 @@
+package pdfextract
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// An open document costs roughly this many times its file size in memory.
+const sessionMemoryFactor = 4
+
+// MemoryBudget limits the estimated memory held by open document sessions.
+// A single request larger than the whole budget is still let through when
+// nothing else is open, so one huge file cannot stall the batch.
+type MemoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+// NewMemoryBudget makes a budget of limit bytes.
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	b := &MemoryBudget{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *MemoryBudget) fits(n int64) bool {
+	return b.used == 0 || b.used+n <= b.limit
+}
+
+func (b *MemoryBudget) acquire(n int64) {
+	b.mu.Lock()
+	for !b.fits(n) {
+		b.cond.Wait()
+	}
+	b.used += n
+	b.mu.Unlock()
+}
+
+func (b *MemoryBudget) tryAcquire(n int64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.fits(n) {
+		return false
+	}
+	b.used += n
+	return true
+}
+
+func (b *MemoryBudget) release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+// batch is the shared state of one run over all input files.
+type batch struct {
+	ctx     context.Context
+	opts    Options
+	report  *Report
+	budget  *MemoryBudget
+	outputs *outputRegistry
+	aborted atomic.Bool
+}
+
+// stopped reports whether the run was aborted or cancelled.
+func (b *batch) stopped() bool {
+	return b.aborted.Load() || b.ctx.Err() != nil
+}
+
+// fileJob tracks the pages of one input file while they are spread across
+// the workers. Each worker borrows a session of its own, so the unipdf and
+// ledongthuc readers are never shared between goroutines, and finished pages
+// are written out strictly in page order.
+type fileJob struct {
+	b        *batch
+	path     string
+	cost     int64
+	out      *fileOutput
+	pages    []int
+	sessions chan *docSession
+	ckpt     *checkpoint
+
+	mu       sync.Mutex
+	open     int
+	done     map[int]*pageResult
+	next     int
+	pending  int
+	stopped  bool
+	failures int
+}
+
+// session hands out an idle session, opens another one when the memory
+// budget allows it, or else waits for one to be returned.
+func (j *fileJob) session() (*docSession, error) {
+	select {
+	case s := <-j.sessions:
+		return s, nil
+	default:
+	}
+	if j.b.budget.tryAcquire(j.cost) {
+		s, err := openDocSession(j.path, j.b.opts)
+		if err != nil {
+			j.b.budget.release(j.cost)
+			return nil, WithCategory(CategoryOpen, err)
+		}
+		j.mu.Lock()
+		j.open++
+		j.mu.Unlock()
+		return s, nil
+	}
+	return <-j.sessions, nil
+}
+
+// skipRest reports whether the remaining pages of the file should be skipped.
+func (j *fileJob) skipRest() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.stopped || j.b.stopped()
+}
+
+// fail records a page failure and applies the error policy. Must be called with j.mu held.
+func (j *fileJob) fail(pageNum int, err error) {
+	j.b.report.AddFailure(j.path, pageNum, err)
+	j.failures++
+	switch j.b.opts.OnError {
+	case SkipFile:
+		j.stopped = true
+	case AbortRun:
+		j.stopped = true
+		j.b.aborted.Store(true)
+	}
+}
+
+// complete records the outcome of one page, writes every page that is now
+// next in order, and reports whether this was the last page of the file.
+func (j *fileJob) complete(idx int, r *pageResult, err error) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err != nil && !j.stopped {
+		j.fail(j.pages[idx], err)
+	}
+	j.done[idx] = r
+	j.flush()
+	j.pending--
+	return j.pending == 0
+}
+
+// flush writes every finished page that is next in page order and records
+// it in the checkpoint. Must be called with j.mu held.
+func (j *fileJob) flush() {
+	for !j.stopped {
+		r, ok := j.done[j.next]
+		if !ok {
+			break
+		}
+		delete(j.done, j.next)
+		j.next++
+		if r != nil {
+			j.writePage(r)
+		}
+		j.reportProgress(false)
+	}
+}
+
+// Must be called with j.mu held.
+func (j *fileJob) writePage(r *pageResult) {
+	if err := j.out.writePage(r); err != nil {
+		j.fail(r.PageNum, err)
+		return
+	}
+	if !r.Restored {
+		if err := j.ckpt.recordPage(r); err != nil {
+			j.fail(r.PageNum, WithCategory(CategoryWrite, err))
+			return
+		}
+		j.b.report.addPage()
+	}
+}
+
+// Must be called with j.mu held.
+func (j *fileJob) reportProgress(done bool) {
+	if j.b.opts.OnProgress != nil {
+		j.b.opts.OnProgress(Progress{
+			Path:      j.path,
+			Pages:     len(j.pages),
+			PagesDone: j.next,
+			Photos:    j.out.totalExtracted,
+			Done:      done,
+		})
+	}
+}
+
+// finish closes every session of the file and writes its summary output,
+// unless the file was given up on. A cancelled file keeps the output of its
+// finished pages and is resumed by the next run.
+func (j *fileJob) finish() {
+	for j.open > 0 {
+		s := <-j.sessions
+		s.Close()
+		j.open--
+		j.b.budget.release(j.cost)
+	}
+	defer j.ckpt.Close()
+	if j.stopped || j.b.aborted.Load() {
+		return
+	}
+	if err := j.out.finish(); err != nil {
+		j.b.report.AddFailure(j.path, 0, err)
+		if j.b.opts.OnError == AbortRun {
+			j.b.aborted.Store(true)
+		}
+		return
+	}
+	if j.b.ctx.Err() != nil {
+		return
+	}
+	// Files with failed pages stay open so the next run retries those pages
+	if j.failures == 0 {
+		if err := j.ckpt.markDone(); err != nil {
+			j.b.report.AddFailure(j.path, 0, WithCategory(CategoryWrite, err))
+		}
+	}
+	j.b.report.addCompleted()
+	j.mu.Lock()
+	j.reportProgress(true)
+	j.mu.Unlock()
+}
+
+type pageUnit struct {
+	job *fileJob
+	idx int
+}
+
+// Extracts every input file with a fixed number of workers. Pages of all
+// files go through one queue, so a single large file still uses every worker
+// while a large batch never has more documents open than the memory budget
+// allows. Failures are recorded in the report and handled by the policy.
+func runBatch(files []string, b *batch) {
+	units := make(chan pageUnit)
+
+	go func() {
+		defer close(units)
+		for _, input := range files {
+			if b.stopped() {
+				return
+			}
+			job, err := b.startFileJob(input)
+			if err != nil {
+				b.report.AddFailure(input, 0, err)
+				if b.opts.OnError == AbortRun {
+					b.aborted.Store(true)
+				}
+				continue
+			}
+			if job == nil {
+				continue
+			}
+			if job.pending == 0 {
+				job.finish()
+				continue
+			}
+			for idx := range job.pages {
+				if _, restored := job.ckpt.pages[job.pages[idx]]; restored {
+					continue
+				}
+				units <- pageUnit{job: job, idx: idx}
+			}
+		}
+	}()
+
+	wg := sync.WaitGroup{}
+	for range b.opts.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range units {
+				job := u.job
+				var (
+					r   *pageResult
+					err error
+				)
+				if !job.skipRest() {
+					var doc *docSession
+					if doc, err = job.session(); err == nil {
+						r, err = extractPage(b.ctx, doc, job.pages[u.idx], b.opts)
+						job.sessions <- doc
+					}
+				}
+				// A page cut short by cancellation is not a failure, the
+				// next run simply does it again
+				if err != nil && b.ctx.Err() != nil && errors.Is(err, b.ctx.Err()) {
+					r, err = nil, nil
+				}
+				if job.complete(u.idx, r, err) {
+					job.finish()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Opens the first session of a file to learn its pages and queues the file.
+// Pages finished by an earlier run are restored from the checkpoint; a file
+// that was finished completely yields no job at all.
+func (b *batch) startFileJob(input string) (*fileJob, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, WithCategory(CategoryInput, err)
+	}
+	cost := info.Size() * sessionMemoryFactor
+
+	b.budget.acquire(cost)
+	doc, err := openDocSession(input, b.opts)
+	if err != nil {
+		b.budget.release(cost)
+		return nil, WithCategory(CategoryOpen, err)
+	}
+	// The output folder can depend on the cover page, so the roll metadata
+	// is read before the checkpoint for that folder is looked up.
+	meta := readRollMetadata(doc, b.opts.Template)
+	abandon := func() {
+		doc.Close()
+		b.budget.release(cost)
+	}
+
+	ckpt, err := openCheckpoint(b.opts.StateDir, input, pdfOutputDir(input, meta, b.opts), b.opts, b.opts.Force)
+	if err != nil {
+		abandon()
+		return nil, WithCategory(CategoryOther, fmt.Errorf("checkpoint: %v", err))
+	}
+	if ckpt.done {
+		ckpt.Close()
+		abandon()
+		b.report.addSkipped()
+		doc.log.Info("Skipping file, already completed", "checkpoint", ckpt.hash)
+		return nil, nil
+	}
+
+	out, err := newFileOutput(input, meta, b)
+	if err != nil {
+		ckpt.Close()
+		abandon()
+		return nil, err
+	}
+	doc.log.Info("Processing file", "pages", doc.numPages)
+
+	var pages []int
+	for pageNum := 1; pageNum <= doc.numPages; pageNum++ {
+		if !b.opts.Template.skipPage(pageNum) {
+			pages = append(pages, pageNum)
+		}
+	}
+
+	job := &fileJob{
+		b:        b,
+		path:     input,
+		cost:     cost,
+		out:      out,
+		pages:    pages,
+		sessions: make(chan *docSession, b.opts.Workers),
+		ckpt:     ckpt,
+		open:     1,
+		done:     make(map[int]*pageResult),
+		pending:  len(pages),
+	}
+	for idx, pageNum := range pages {
+		if r, ok := ckpt.pages[pageNum]; ok {
+			job.done[idx] = r
+			job.pending--
+		}
+	}
+	if restored := len(pages) - job.pending; restored > 0 {
+		doc.log.Info("Resuming file", "pages_done", restored, "pages", len(pages))
+	}
+	job.mu.Lock()
+	job.reportProgress(false)
+	job.flush()
+	job.mu.Unlock()
+
+	job.sessions <- doc
+	return job, nil
+}
@@ -0,0 +1,262 @@
+package pdfextract
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Pages of the test rolls: the cover page, which is skipped, and pages of
+// 12 photos each.
+const (
+	testRollPages  = 4
+	testPhotoPages = testRollPages - 1
+)
+
+func testOptions(outputDir string) Options {
+	return Options{
+		OutputDir: outputDir,
+		Backend:   OfflineBackend,
+		Workers:   4,
+		Logger:    slog.New(slog.DiscardHandler),
+	}
+}
+
+func runTestBatch(t *testing.T, ctx context.Context, opts Options, files ...string) (*Report, error) {
+	t.Helper()
+	ex, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := NewReport(ex.Options().OnError)
+	err = ex.Run(ctx, files, report)
+	if failures := report.FailureList(); len(failures) > 0 {
+		t.Errorf("failures: %+v", failures)
+	}
+	return report, err
+}
+
+// outputFiles lists the files written under dir, checkpoints left out, with
+// their modification times.
+func outputFiles(t *testing.T, dir string) map[string]time.Time {
+	t.Helper()
+	files := make(map[string]time.Time)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".checkpoints" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[path] = info.ModTime()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// checkpointPages reads the pages recorded in the only checkpoint in dir,
+// and whether the file was marked done.
+func checkpointPages(t *testing.T, dir string) (map[int][]ManifestEntry, bool) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("checkpoints %v (%v), want one", paths, err)
+	}
+	f, err := os.Open(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	pages := make(map[int][]ManifestEntry)
+	done := false
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var line checkpointLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("checkpoint line %q: %v", scanner.Text(), err)
+		}
+		if line.Done {
+			done = true
+			continue
+		}
+		pages[line.Page] = line.Photos
+	}
+	return pages, done
+}
+
+func TestRunResume(t *testing.T) {
+	roll := writeTestRoll(t, testRollPages)
+	out := t.TempDir()
+	opts := testOptions(out)
+	ctx := context.Background()
+
+	report, err := runTestBatch(t, ctx, opts, roll)
+	if err != nil || report.CompletedFiles != 1 || report.PagesWritten != testPhotoPages {
+		t.Fatalf("first run: %v, %d files and %d pages done", err, report.CompletedFiles, report.PagesWritten)
+	}
+	written := outputFiles(t, out)
+	photos := 0
+	for path := range written {
+		if strings.HasSuffix(path, ".jpg") {
+			photos++
+		}
+	}
+	if photos != 12*testPhotoPages {
+		t.Fatalf("%d photos written, want %d", photos, 12*testPhotoPages)
+	}
+
+	// A finished file is skipped as a whole
+	report, err = runTestBatch(t, ctx, opts, roll)
+	if err != nil || report.SkippedFiles != 1 || report.PagesWritten != 0 {
+		t.Fatalf("second run: %v, %d files skipped and %d pages written", err, report.SkippedFiles, report.PagesWritten)
+	}
+	if again := outputFiles(t, out); len(again) != len(written) {
+		t.Errorf("second run: %d files, want %d", len(again), len(written))
+	} else {
+		for path, mod := range again {
+			if !mod.Equal(written[path]) {
+				t.Errorf("second run rewrote %s", path)
+			}
+		}
+	}
+
+	// As after a crash before the file was marked done: every page is
+	// restored from the checkpoint and no photo is written again
+	ckptDir := filepath.Join(out, ".checkpoints")
+	paths, _ := filepath.Glob(filepath.Join(ckptDir, "*.jsonl"))
+	data, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	if err := os.WriteFile(paths[0], []byte(strings.Join(lines[:len(lines)-1], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	rewritten := 0
+	opts.OnPhoto = func(p ExtractedPhoto) {
+		if p.Data != nil {
+			mu.Lock()
+			rewritten++
+			mu.Unlock()
+		}
+	}
+	report, err = runTestBatch(t, ctx, opts, roll)
+	if err != nil || report.CompletedFiles != 1 || report.PagesWritten != 0 {
+		t.Fatalf("resumed run: %v, %d files done and %d pages written", err, report.CompletedFiles, report.PagesWritten)
+	}
+	if rewritten > 0 {
+		t.Errorf("resumed run wrote %d photos again", rewritten)
+	}
+	for path, mod := range outputFiles(t, out) {
+		if strings.HasSuffix(path, ".jpg") && !mod.Equal(written[path]) {
+			t.Errorf("resumed run rewrote %s", path)
+		}
+	}
+	if _, done := checkpointPages(t, ckptDir); !done {
+		t.Error("resumed run did not mark the file done")
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	roll := writeTestRoll(t, testRollPages)
+	out := t.TempDir()
+	opts := testOptions(out)
+	opts.Workers = 2
+
+	// Stop once the first page with photos is written
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts.OnPhoto = func(ExtractedPhoto) { cancel() }
+	report, err := runTestBatch(t, ctx, opts, roll)
+	if err != context.Canceled || !report.Interrupted {
+		t.Fatalf("run returned %v, interrupted %v; want cancelled", err, report.Interrupted)
+	}
+
+	files := outputFiles(t, out)
+	for path := range files {
+		if strings.Contains(filepath.Base(path), ".tmp-") {
+			t.Errorf("temporary file left: %s", path)
+		}
+	}
+	pages, done := checkpointPages(t, filepath.Join(out, ".checkpoints"))
+	if done {
+		t.Error("cancelled file marked done")
+	}
+	if len(pages) == 0 || len(pages) >= testPhotoPages {
+		t.Errorf("%d pages in the checkpoint, want some but not all %d", len(pages), testPhotoPages)
+	}
+	// Only pages whose photos were all written are in the checkpoint
+	for page, photos := range pages {
+		for _, e := range photos {
+			if _, ok := files[filepath.Join(out, "roll", e.OutputFile)]; !ok {
+				t.Errorf("page %d in the checkpoint, but %s was not written", page, e.OutputFile)
+			}
+		}
+	}
+
+	// The next run finishes the rest
+	opts.OnPhoto = nil
+	report, err = runTestBatch(t, context.Background(), opts, roll)
+	if err != nil || report.CompletedFiles != 1 || report.PagesWritten != testPhotoPages-len(pages) {
+		t.Fatalf("resumed run: %v, %d files done and %d pages written, want %d",
+			err, report.CompletedFiles, report.PagesWritten, testPhotoPages-len(pages))
+	}
+	var manifest []ManifestEntry
+	data, err := os.ReadFile(filepath.Join(out, "roll", "manifest.json"))
+	if err == nil {
+		err = json.Unmarshal(data, &manifest)
+	}
+	if err != nil || len(manifest) != 12*testPhotoPages {
+		t.Errorf("manifest of %d photos (%v), want %d", len(manifest), err, 12*testPhotoPages)
+	}
+}
+
+func TestRunSmallMemoryBudget(t *testing.T) {
+	// Two rolls, each larger than the whole budget: they are still
+	// extracted, one document at a time
+	first := writeTestRoll(t, testRollPages)
+	second := filepath.Join(filepath.Dir(writeTestRoll(t, testRollPages)), "roll2.pdf")
+	if err := os.Rename(filepath.Join(filepath.Dir(second), "roll.pdf"), second); err != nil {
+		t.Fatal(err)
+	}
+	out := t.TempDir()
+	opts := testOptions(out)
+	opts.MaxMemory = 1
+
+	done := make(chan struct{})
+	var report *Report
+	go func() {
+		defer close(done)
+		report, _ = runTestBatch(t, context.Background(), opts, first, second)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("batch did not finish with a small memory budget")
+	}
+	if report.CompletedFiles != 2 || report.PagesWritten != 2*testPhotoPages {
+		t.Errorf("%d files done and %d pages written, want 2 and %d", report.CompletedFiles, report.PagesWritten, 2*testPhotoPages)
+	}
+}
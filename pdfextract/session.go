@@ -0,0 +1,140 @@
+package pdfextract
+
+import (
+	"fmt"
+	"image"
+	"log/slog"
+	"os"
+)
+
+// docSession keeps one input PDF open for the whole extraction. The file is
+// opened and parsed once by each library the backend uses, which then serve
+// the text, text layout and images of every page.
+type docSession struct {
+	path     string
+	file     *os.File
+	text     TextSource
+	layout   TextSource
+	images   ImageSource
+	numPages int
+	// log is the extraction's logger with the file attribute.
+	log *slog.Logger
+}
+
+func openDocSession(path string, opts Options) (*docSession, error) {
+	backend := opts.Backend
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	// unipdf reads through Seek/Read and ledongthuc/pdf through ReadAt, so
+	// both can share the same file handle.
+	sources := make(map[string]TextSource)
+	var images ImageSource
+	numPages := 0
+	keepRaw := opts.Format == FormatJPEG || opts.Format == FormatOriginal
+	if backend.uses(BackendUnipdf) {
+		src, n, err := newUnipdfSource(f, keepRaw)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		sources[BackendUnipdf], images, numPages = src, src, n
+	}
+	if backend.Images == BackendOffline {
+		src, n, err := newOfflineSource(f, keepRaw)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		images, numPages = src, n
+	}
+	if backend.uses(BackendLedongthuc) {
+		src, n, err := newLedongthucSource(f, info.Size())
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		sources[BackendLedongthuc] = src
+		if numPages == 0 {
+			numPages = n
+		}
+	}
+	if images == nil {
+		f.Close()
+		return nil, fmt.Errorf("no image backend in %s", backend)
+	}
+
+	return &docSession{
+		path:     path,
+		file:     f,
+		text:     sources[backend.Text],
+		layout:   sources[backend.Layout],
+		images:   images,
+		numPages: numPages,
+		log:      opts.Logger.With("file", path),
+	}, nil
+}
+
+func (s *docSession) Close() error {
+	return s.file.Close()
+}
+
+// pageFonts returns the base font names used on a page.
+func (s *docSession) pageFonts(pageNum int) []string {
+	fonts, err := s.text.Fonts(pageNum)
+	if err != nil {
+		s.log.Warn("Could not read fonts", "page", pageNum, "error", err)
+	}
+	return fonts
+}
+
+// pageText is the plain text of a page, normalized for parsing. Text set in
+// a legacy font (font, or one found on the page with legacyFontAuto) is
+// converted to Unicode run by run, leaving text in other fonts alone. When
+// the template names a legacy font but no font on the page is named like
+// one, all of the text is taken to be set in it.
+func (s *docSession) pageText(pageNum int, font legacyFont) (string, error) {
+	detected := font == legacyFontAuto
+	if detected {
+		font = detectLegacyFont(s.pageFonts(pageNum))
+	}
+	if font == legacyFontNone {
+		text, err := s.text.PlainText(pageNum)
+		if err != nil {
+			return "", err
+		}
+		return normalizeText(text), nil
+	}
+
+	runs, err := s.text.FontRuns(pageNum)
+	if err != nil {
+		return "", err
+	}
+	text, converted := convertLegacyRuns(runs)
+	if !converted && !detected {
+		text = preetiToUnicode(text)
+		converted = true
+	}
+	if converted {
+		s.log.Debug("Converted legacy font text to Unicode", "page", pageNum, "font", font)
+	}
+	return normalizeText(text), nil
+}
+
+// renderPage draws a page when the image backend can, for debug overlays.
+func (s *docSession) renderPage(pageNum, width int) (image.Image, error) {
+	r, ok := s.images.(interface {
+		renderPage(pageNum, width int) (image.Image, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("the image backend cannot render pages")
+	}
+	return r.renderPage(pageNum, width)
+}
@@ -0,0 +1,131 @@
+package pdfextract
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+)
+
+// Pages of the benchmark roll, as many as in a typical roll.
+const benchPages = 200
+
+// Compares reading the text of every page from the PDF opened and parsed
+// again for each page, as extraction did before documents were shared, with
+// one ledongthuc/pdf reader serving all pages.
+func BenchmarkPageText(b *testing.B) {
+	path := writeTestRoll(b, benchPages)
+	openText := func(b *testing.B) (*ledongthucSource, *os.File) {
+		f, err := os.Open(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			b.Fatal(err)
+		}
+		src, _, err := newLedongthucSource(f, info.Size())
+		if err != nil {
+			b.Fatal(err)
+		}
+		return src, f
+	}
+	readText := func(b *testing.B, src *ledongthucSource, pageNum int) {
+		if _, err := src.PlainText(pageNum); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("reopen-per-page", func(b *testing.B) {
+		for b.Loop() {
+			for pageNum := 2; pageNum <= benchPages; pageNum++ {
+				src, f := openText(b)
+				readText(b, src, pageNum)
+				f.Close()
+			}
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*(benchPages-1)), "ns/page")
+	})
+
+	b.Run("shared-session", func(b *testing.B) {
+		for b.Loop() {
+			src, f := openText(b)
+			for pageNum := 2; pageNum <= benchPages; pageNum++ {
+				readText(b, src, pageNum)
+			}
+			f.Close()
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*(benchPages-1)), "ns/page")
+	})
+}
+
+// Compares whole pages, text and images, the same way: text from a PDF
+// opened again for every page next to one unipdf reader for the images, as
+// before, or everything from one docSession. Uses the ledongthuc backend
+// (text from ledongthuc/pdf, images from unipdf), which reads unlicensed.
+func BenchmarkPageExtraction(b *testing.B) {
+	path := writeTestRoll(b, benchPages)
+	backend, err := ParseBackend(BackendLedongthuc)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ex, err := New(Options{Backend: backend, Logger: slog.New(slog.DiscardHandler)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	opts := ex.Options()
+	ctx := context.Background()
+
+	extract := func(b *testing.B, doc *docSession, pageNum int) {
+		r, err := extractPage(ctx, doc, pageNum, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(r.Photos) != 12 {
+			b.Fatalf("page %d: got %d photos, want 12", pageNum, len(r.Photos))
+		}
+	}
+
+	b.Run("reopen-per-page", func(b *testing.B) {
+		for b.Loop() {
+			doc, err := openDocSession(path, opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for pageNum := 2; pageNum <= benchPages; pageNum++ {
+				f, err := os.Open(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				info, err := f.Stat()
+				if err != nil {
+					b.Fatal(err)
+				}
+				text, _, err := newLedongthucSource(f, info.Size())
+				if err != nil {
+					b.Fatal(err)
+				}
+				page := *doc
+				page.text, page.layout = text, text
+				extract(b, &page, pageNum)
+				f.Close()
+			}
+			doc.Close()
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*(benchPages-1)), "ns/page")
+	})
+
+	b.Run("shared-session", func(b *testing.B) {
+		for b.Loop() {
+			doc, err := openDocSession(path, opts)
+			if err != nil {
+				b.Fatal(err)
+			}
+			for pageNum := 2; pageNum <= benchPages; pageNum++ {
+				extract(b, doc, pageNum)
+			}
+			doc.Close()
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*(benchPages-1)), "ns/page")
+	})
+}
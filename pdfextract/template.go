@@ -0,0 +1,213 @@
+package pdfextract
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultIDPattern = `\b(\d{4,10})\b`
+
+// Template describes the page layout of one roll format.
+type Template struct {
+	Name string `yaml:"name" json:"name"`
+	// Page numbers (1-based) that hold no voter records.
+	SkipPages []int `yaml:"skip_pages" json:"skip_pages"`
+	// Which images on a page are logos or other header art rather than photos.
+	HeaderImages headerRule `yaml:"header_images" json:"header_images"`
+	// Grid of record cells; when empty a cell is guessed around each photo.
+	Grid *gridLayout `yaml:"grid,omitempty" json:"grid,omitempty"`
+	// Regular expression for voter ID candidates. The first capture group, or
+	// the whole match when there is none, is the ID.
+	IDPattern string `yaml:"id_pattern" json:"id_pattern"`
+	// Legacy font the roll is typeset in: auto (detect from font names), none,
+	// preeti or kantipur. Only text set in fonts named like it is converted,
+	// or all text when a legacy font is named here but none is on the page.
+	LegacyFont legacyFont `yaml:"legacy_font" json:"legacy_font"`
+	// Rules every ID candidate must pass, in order. Left out, only year-like
+	// numbers are rejected; an empty list accepts every candidate.
+	IDValidators []ValidatorSpec `yaml:"id_validators" json:"id_validators"`
+
+	ids  idMatcher
+	skip map[int]bool
+}
+
+// region is a rectangle in PDF user space, (X, Y) being its lower left corner.
+type region struct {
+	X      float64 `yaml:"x" json:"x"`
+	Y      float64 `yaml:"y" json:"y"`
+	Width  float64 `yaml:"width" json:"width"`
+	Height float64 `yaml:"height" json:"height"`
+}
+
+func (r region) box() box {
+	return box{X: r.X, Y: r.Y, W: r.Width, H: r.Height}
+}
+
+type headerRule struct {
+	// The first Count images of a page are headers ...
+	Count int `yaml:"count" json:"count"`
+	// ... but only on pages with more than MinImages images.
+	MinImages int `yaml:"min_images" json:"min_images"`
+	// Images centred inside any of these regions are headers.
+	Regions []region `yaml:"regions" json:"regions"`
+}
+
+// gridLayout is the area holding the record cells, (X, Y) being its lower left corner.
+type gridLayout struct {
+	X       float64 `yaml:"x" json:"x"`
+	Y       float64 `yaml:"y" json:"y"`
+	Width   float64 `yaml:"width" json:"width"`
+	Height  float64 `yaml:"height" json:"height"`
+	Rows    int     `yaml:"rows" json:"rows"`
+	Columns int     `yaml:"columns" json:"columns"`
+}
+
+// cellAt returns the grid cell that contains the point.
+func (g *gridLayout) cellAt(x, y float64) (box, bool) {
+	area := box{X: g.X, Y: g.Y, W: g.Width, H: g.Height}
+	if !area.contains(x, y) {
+		return box{}, false
+	}
+	w := area.W / float64(g.Columns)
+	h := area.H / float64(g.Rows)
+	col := min(int((x-area.X)/w), g.Columns-1)
+	row := min(int((y-area.Y)/h), g.Rows-1)
+	return box{X: area.X + float64(col)*w, Y: area.Y + float64(row)*h, W: w, H: h}, true
+}
+
+// The layout the tool has always assumed: no records on the cover page and
+// three logos at the top of every other page.
+func DefaultTemplate() *Template {
+	t := &Template{
+		Name:         "default",
+		SkipPages:    []int{1},
+		HeaderImages: headerRule{Count: 3},
+		IDPattern:    defaultIDPattern,
+		LegacyFont:   legacyFontAuto,
+	}
+	if err := t.compile(); err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Loads a layout template from a .yaml, .yml or .json file.
+func LoadTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Template{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, t)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, t)
+	default:
+		return nil, fmt.Errorf("template %s: unsupported file type (want .yaml, .yml or .json)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("template %s: %v", path, err)
+	}
+	if err := t.compile(); err != nil {
+		return nil, fmt.Errorf("template %s: %v", path, err)
+	}
+	return t, nil
+}
+
+func (t *Template) compile() error {
+	if t.IDPattern == "" {
+		t.IDPattern = defaultIDPattern
+	}
+	re, err := regexp.Compile(t.IDPattern)
+	if err != nil {
+		return fmt.Errorf("invalid id_pattern: %v", err)
+	}
+	if t.IDValidators == nil {
+		t.IDValidators = defaultIDValidators()
+	}
+	validators, err := buildValidators(t.IDValidators)
+	if err != nil {
+		return fmt.Errorf("invalid id_validators: %v", err)
+	}
+	t.ids = idMatcher{pattern: re, validators: validators}
+
+	switch t.LegacyFont {
+	case "":
+		t.LegacyFont = legacyFontAuto
+	case legacyFontAuto, legacyFontNone, legacyFontPreeti, legacyFontKantipur:
+	default:
+		return fmt.Errorf("unknown legacy_font %q (want auto, none, preeti or kantipur)", t.LegacyFont)
+	}
+
+	if g := t.Grid; g != nil {
+		if g.Rows <= 0 || g.Columns <= 0 || g.Width <= 0 || g.Height <= 0 {
+			return fmt.Errorf("grid needs positive rows, columns, width and height")
+		}
+	}
+
+	t.skip = make(map[int]bool, len(t.SkipPages))
+	for _, p := range t.SkipPages {
+		t.skip[p] = true
+	}
+	return nil
+}
+
+func (t *Template) skipPage(pageNum int) bool {
+	return t.skip[pageNum]
+}
+
+// Splits the images of a page into voter photos and header images.
+func (t *Template) splitHeaderImages(photos []photoMark) (kept, headers []photoMark) {
+	rule := t.HeaderImages
+	byCount := len(photos) > rule.MinImages
+	for i, p := range photos {
+		if byCount && i < rule.Count || inAnyRegion(p.Box, rule.Regions) {
+			headers = append(headers, p)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, headers
+}
+
+func inAnyRegion(b box, regions []region) bool {
+	x, y := b.center()
+	for _, r := range regions {
+		if r.box().contains(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCell is the area around a photo that belongs to the same voter record.
+func (t *Template) recordCell(photo box) box {
+	if t.Grid != nil {
+		if cell, ok := t.Grid.cellAt(photo.center()); ok {
+			return cell
+		}
+	}
+	return guessRecordCell(photo)
+}
+
+// idSpan returns the byte offsets of the ID inside a submatch index slice.
+func idSpan(loc []int) (int, int) {
+	if len(loc) >= 4 && loc[2] >= 0 {
+		return loc[2], loc[3]
+	}
+	return loc[0], loc[1]
+}
+
+// SetIDValidators replaces the rules ID candidates must pass.
+func (t *Template) SetIDValidators(specs []ValidatorSpec) error {
+	t.IDValidators = specs
+	return t.compile()
+}
@@ -0,0 +1,254 @@
+package pdfextract
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/render"
+)
+
+// unipdfSource reads text and images with unipdf. Its text extraction
+// needs a unipdf license, like everything else in unipdf.
+type unipdfSource struct {
+	reader *model.PdfReader
+	// keepRaw also looks up the embedded stream of JPEG and JPEG 2000
+	// images, so they can be written unchanged.
+	keepRaw bool
+}
+
+func newUnipdfSource(f *os.File, keepRaw bool) (*unipdfSource, int, error) {
+	reader, err := model.NewPdfReaderWithOpts(f, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	numPages, err := reader.GetNumPages()
+	if err != nil {
+		return nil, 0, err
+	}
+	return &unipdfSource{reader: reader, keepRaw: keepRaw}, numPages, nil
+}
+
+func (s *unipdfSource) extractor(pageNum int) (*model.PdfPage, *extractor.Extractor, error) {
+	page, err := s.reader.GetPage(pageNum)
+	if err != nil {
+		return nil, nil, err
+	}
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, nil, err
+	}
+	return page, ex, nil
+}
+
+func (s *unipdfSource) PlainText(pageNum int) (string, error) {
+	_, ex, err := s.extractor(pageNum)
+	if err != nil {
+		return "", err
+	}
+	return ex.ExtractText()
+}
+
+func (s *unipdfSource) Layout(pageNum int) (*TextLayout, error) {
+	_, ex, err := s.extractor(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	layout := &TextLayout{Text: pageText.Text()}
+	for _, m := range pageText.Marks().Elements() {
+		// Spaces and line breaks inserted by the extractor
+		if m.Meta {
+			continue
+		}
+		layout.Spans = append(layout.Spans, TextSpan{
+			Offset: m.Offset,
+			Len:    len(m.Text),
+			Box:    manifestBox(m.BBox),
+		})
+	}
+	return layout, nil
+}
+
+// FontRuns cuts the extracted text at the marks where the font changes;
+// the spaces and line breaks unipdf inserts have no font.
+func (s *unipdfSource) FontRuns(pageNum int) ([]FontRun, error) {
+	_, ex, err := s.extractor(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	text := pageText.Text()
+	var runs []FontRun
+	pos := 0
+	for _, m := range pageText.Marks().Elements() {
+		end := m.Offset + len(m.Text)
+		if m.Offset < pos || end > len(text) {
+			continue
+		}
+		font := ""
+		if !m.Meta && m.Font != nil {
+			font = m.Font.BaseFont()
+		}
+		runs = appendRun(runs, text[pos:m.Offset], "")
+		runs = appendRun(runs, text[m.Offset:end], font)
+		pos = end
+	}
+	return appendRun(runs, text[pos:], ""), nil
+}
+
+func (s *unipdfSource) Fonts(pageNum int) ([]string, error) {
+	page, err := s.reader.GetPage(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	if page.Resources == nil {
+		return nil, nil
+	}
+	fontDict, ok := core.GetDict(page.Resources.Font)
+	if !ok {
+		return nil, nil
+	}
+	var fonts []string
+	for _, name := range fontDict.Keys() {
+		obj, ok := page.Resources.GetFontByName(name)
+		if !ok {
+			continue
+		}
+		font, err := model.NewPdfFontFromPdfObject(obj)
+		if err != nil {
+			continue
+		}
+		fonts = append(fonts, font.BaseFont())
+	}
+	return fonts, nil
+}
+
+func (s *unipdfSource) Images(pageNum int) (*PageImages, error) {
+	page, ex, err := s.extractor(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	mediaBox, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	marks, err := ex.ExtractPageImages(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var raws map[sampleKey]RawImage
+	if s.keepRaw {
+		raws = pageRawImages(page)
+	}
+	images := &PageImages{MediaBox: manifestBox(*mediaBox)}
+	for _, mark := range marks.Images {
+		gimg, err := mark.Image.ToGoImage()
+		if err != nil {
+			return nil, err
+		}
+		img := PageImage{
+			Box:   ManifestBox{X: mark.X, Y: mark.Y, Width: mark.Width, Height: mark.Height},
+			Image: gimg,
+		}
+		if raw, ok := raws[imageSampleKey(mark.Image)]; ok {
+			img.Raw = &raw
+		}
+		images.Images = append(images.Images, img)
+	}
+	return images, nil
+}
+
+// renderPage draws a page as an image width pixels wide.
+func (s *unipdfSource) renderPage(pageNum, width int) (image.Image, error) {
+	page, err := s.reader.GetPage(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	device := render.NewImageDevice()
+	device.OutputWidth = width
+	return device.Render(page)
+}
+
+func manifestBox(r model.PdfRectangle) ManifestBox {
+	return ManifestBox{X: r.Llx, Y: r.Lly, Width: r.Urx - r.Llx, Height: r.Ury - r.Lly}
+}
+
+// Filters whose encoded stream is a complete image file on its own.
+var passthroughFilters = map[core.PdfObjectName]RawImage{
+	core.StreamEncodingFilterNameDCT: {Encoding: "jpeg", Extension: ".jpg"},
+	core.StreamEncodingFilterNameJPX: {Encoding: "jpeg2000", Extension: ".jp2"},
+}
+
+type sampleKey [sha256.Size]byte
+
+func imageSampleKey(img *model.Image) sampleKey {
+	h := sha256.New()
+	fmt.Fprintf(h, "%dx%dx%dx%d:", img.Width, img.Height, img.ColorComponents, img.BitsPerComponent)
+	h.Write(img.Data)
+	var k sampleKey
+	copy(k[:], h.Sum(nil))
+	return k
+}
+
+// Collects the image XObjects of a page that are stored as JPEG or JPEG 2000,
+// keyed by a hash of their decoded samples. The image marks found by the
+// extractor only carry decoded samples, so the same hash leads from a mark
+// back to the original stream.
+func pageRawImages(page *model.PdfPage) map[sampleKey]RawImage {
+	raws := make(map[sampleKey]RawImage)
+	if page.Resources == nil {
+		return raws
+	}
+	xobjects, ok := core.GetDict(page.Resources.XObject)
+	if !ok {
+		return raws
+	}
+
+	for _, name := range xobjects.Keys() {
+		stream, xtype := page.Resources.GetXObjectByName(name)
+		if stream == nil || xtype != model.XObjectTypeImage {
+			continue
+		}
+		raw, ok := passthroughFilters[streamFilter(stream)]
+		if !ok {
+			continue
+		}
+		ximg, err := model.NewXObjectImageFromStream(stream)
+		if err != nil {
+			continue
+		}
+		img, err := ximg.ToImage()
+		if err != nil {
+			continue
+		}
+		raw.Data = stream.Stream
+		raws[imageSampleKey(img)] = raw
+	}
+	return raws
+}
+
+// streamFilter returns the only filter of a stream, or "" if it has none or several.
+func streamFilter(stream *core.PdfObjectStream) core.PdfObjectName {
+	filter := core.TraceToDirectObject(stream.Get("Filter"))
+	if name, ok := core.GetName(filter); ok {
+		return *name
+	}
+	if arr, ok := core.GetArray(filter); ok && arr.Len() == 1 {
+		if name, ok := core.GetName(arr.Get(0)); ok {
+			return *name
+		}
+	}
+	return ""
+}
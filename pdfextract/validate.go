@@ -0,0 +1,297 @@
+package pdfextract
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// IDValidator decides whether a candidate number is a voter ID.
+type IDValidator interface {
+	// Name identifies the rule in logs.
+	Name() string
+	// Validate returns why id is rejected, or nil if it is accepted.
+	Validate(id string) error
+}
+
+// lengthValidator accepts IDs with one of the given numbers of digits.
+type lengthValidator struct {
+	lengths []int
+}
+
+func (v lengthValidator) Name() string { return "length" }
+
+func (v lengthValidator) Validate(id string) error {
+	for _, n := range v.lengths {
+		if len(id) == n {
+			return nil
+		}
+	}
+	return fmt.Errorf("has %d digits, want %v", len(id), v.lengths)
+}
+
+// rangeValidator accepts IDs whose numeric value lies in [min, max].
+type rangeValidator struct {
+	min, max uint64
+}
+
+func (v rangeValidator) Name() string { return "range" }
+
+func (v rangeValidator) Validate(id string) error {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("not a number")
+	}
+	if n < v.min || n > v.max {
+		return fmt.Errorf("outside %d-%d", v.min, v.max)
+	}
+	return nil
+}
+
+// prefixValidator accepts IDs starting with one of the given prefixes,
+// typically the district code.
+type prefixValidator struct {
+	prefixes []string
+}
+
+func (v prefixValidator) Name() string { return "prefix" }
+
+func (v prefixValidator) Validate(id string) error {
+	for _, p := range v.prefixes {
+		if strings.HasPrefix(id, p) {
+			return nil
+		}
+	}
+	return fmt.Errorf("does not start with any of %v", v.prefixes)
+}
+
+// notYearValidator rejects four-digit numbers that look like years, in the
+// Gregorian calendar (1900-2099) by default or any range given. Bikram
+// Sambat years such as 2080 fall in the default range as well.
+type notYearValidator struct {
+	min, max int
+}
+
+func (v notYearValidator) Name() string { return "not-year" }
+
+func (v notYearValidator) Validate(id string) error {
+	if len(id) != 4 {
+		return nil
+	}
+	year, _ := strconv.Atoi(id)
+	if year >= v.min && year <= v.max {
+		return fmt.Errorf("looks like a year")
+	}
+	return nil
+}
+
+// checksumValidator accepts IDs whose last digit is the check digit of the
+// others under the given algorithm.
+type checksumValidator struct {
+	algorithm string
+}
+
+func (v checksumValidator) Name() string { return "checksum" }
+
+func (v checksumValidator) Validate(id string) error {
+	var ok bool
+	switch v.algorithm {
+	case "luhn":
+		ok = luhnValid(id)
+	case "mod11":
+		ok = mod11Valid(id)
+	}
+	if !ok {
+		return fmt.Errorf("%s check digit does not match", v.algorithm)
+	}
+	return nil
+}
+
+func luhnValid(id string) bool {
+	sum := 0
+	double := false
+	for i := len(id) - 1; i >= 0; i-- {
+		d := int(id[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return len(id) > 1 && sum%10 == 0
+}
+
+// mod11Valid checks a weighted mod-11 check digit (weights 2, 3, ... from
+// the right), with remainders 10 and 11 mapping to 0.
+func mod11Valid(id string) bool {
+	if len(id) < 2 {
+		return false
+	}
+	sum := 0
+	for i, w := len(id)-2, 2; i >= 0; i, w = i-1, w+1 {
+		sum += int(id[i]-'0') * w
+	}
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		check = 0
+	}
+	return check == int(id[len(id)-1]-'0')
+}
+
+// ValidatorSpec is how a validator is written in a template.
+type ValidatorSpec struct {
+	// Rule is one of length, range, prefix, not-year or checksum.
+	Rule      string   `yaml:"rule" json:"rule"`
+	Lengths   []int    `yaml:"lengths,omitempty" json:"lengths,omitempty"`
+	Min       uint64   `yaml:"min,omitempty" json:"min,omitempty"`
+	Max       uint64   `yaml:"max,omitempty" json:"max,omitempty"`
+	Prefixes  []string `yaml:"prefixes,omitempty" json:"prefixes,omitempty"`
+	Algorithm string   `yaml:"algorithm,omitempty" json:"algorithm,omitempty"`
+}
+
+func (s ValidatorSpec) build() (IDValidator, error) {
+	switch s.Rule {
+	case "length":
+		if len(s.Lengths) == 0 {
+			return nil, fmt.Errorf("length rule needs lengths")
+		}
+		return lengthValidator{lengths: s.Lengths}, nil
+	case "range":
+		if s.Max < s.Min {
+			return nil, fmt.Errorf("range rule needs min <= max")
+		}
+		return rangeValidator{min: s.Min, max: s.Max}, nil
+	case "prefix":
+		if len(s.Prefixes) == 0 {
+			return nil, fmt.Errorf("prefix rule needs prefixes")
+		}
+		for _, p := range s.Prefixes {
+			// An empty prefix would accept every ID
+			if strings.TrimSpace(p) == "" {
+				return nil, fmt.Errorf("prefix rule has an empty prefix")
+			}
+		}
+		return prefixValidator{prefixes: s.Prefixes}, nil
+	case "not-year":
+		v := notYearValidator{min: 1900, max: 2099}
+		if s.Max > 0 {
+			v.min, v.max = int(s.Min), int(s.Max)
+		}
+		return v, nil
+	case "checksum":
+		switch s.Algorithm {
+		case "luhn", "mod11":
+			return checksumValidator{algorithm: s.Algorithm}, nil
+		}
+		return nil, fmt.Errorf("unknown checksum algorithm %q (want luhn or mod11)", s.Algorithm)
+	}
+	return nil, fmt.Errorf("unknown ID rule %q (want length, range, prefix, not-year or checksum)", s.Rule)
+}
+
+// Parses the --id-rule flag syntax: length=8, range=10000000-99999999,
+// prefix=50,51, checksum=luhn, not-year or not-year=2000-2099. Rules that
+// would not work, such as an empty prefix, are an error.
+func ParseIDRule(s string) (ValidatorSpec, error) {
+	rule, arg, _ := strings.Cut(s, "=")
+	spec := ValidatorSpec{Rule: rule}
+
+	parseRange := func() error {
+		lo, hi, ok := strings.Cut(arg, "-")
+		if !ok {
+			return fmt.Errorf("%s: want MIN-MAX, got %q", rule, arg)
+		}
+		var err error
+		if spec.Min, err = strconv.ParseUint(lo, 10, 64); err != nil {
+			return fmt.Errorf("%s: %v", rule, err)
+		}
+		if spec.Max, err = strconv.ParseUint(hi, 10, 64); err != nil {
+			return fmt.Errorf("%s: %v", rule, err)
+		}
+		return nil
+	}
+
+	switch rule {
+	case "length":
+		for _, f := range strings.Split(arg, ",") {
+			n, err := strconv.Atoi(strings.TrimSpace(f))
+			if err != nil {
+				return spec, fmt.Errorf("length: %v", err)
+			}
+			spec.Lengths = append(spec.Lengths, n)
+		}
+	case "range":
+		if err := parseRange(); err != nil {
+			return spec, err
+		}
+	case "not-year":
+		if arg != "" {
+			if err := parseRange(); err != nil {
+				return spec, err
+			}
+		}
+	case "prefix":
+		for _, p := range strings.Split(arg, ",") {
+			spec.Prefixes = append(spec.Prefixes, strings.TrimSpace(p))
+		}
+	case "checksum":
+		spec.Algorithm = arg
+	}
+	if _, err := spec.build(); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// Year-like numbers are the only candidates the tool has always rejected.
+func defaultIDValidators() []ValidatorSpec {
+	return []ValidatorSpec{{Rule: "not-year"}}
+}
+
+func buildValidators(specs []ValidatorSpec) ([]IDValidator, error) {
+	validators := make([]IDValidator, 0, len(specs))
+	for _, s := range specs {
+		v, err := s.build()
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, v)
+	}
+	return validators, nil
+}
+
+// idMatcher finds voter ID candidates in text and filters them.
+type idMatcher struct {
+	pattern    *regexp.Regexp
+	validators []IDValidator
+	// rejected holds the candidates already logged, see forPage.
+	rejected map[string]bool
+}
+
+// forPage returns a matcher that logs each rejected candidate once, however
+// often the ID and record passes over the page come across it.
+func (m idMatcher) forPage() idMatcher {
+	m.rejected = make(map[string]bool)
+	return m
+}
+
+// accept runs id through every validator and logs the rule that rejects it.
+func (m idMatcher) accept(id string, logger *slog.Logger) bool {
+	if m.rejected[id] {
+		return false
+	}
+	for _, v := range m.validators {
+		if err := v.Validate(id); err != nil {
+			logger.Info("Rejected ID candidate", "voter_id", id, "rule", v.Name(), "error", err)
+			if m.rejected != nil {
+				m.rejected[id] = true
+			}
+			return false
+		}
+	}
+	return true
+}
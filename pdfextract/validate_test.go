@@ -0,0 +1,132 @@
+package pdfextract
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		rule   string
+		id     string
+		accept bool
+	}{
+		{"length=8", "50123456", true},
+		{"length=8", "5012345", false},
+		{"length=8,10", "5012345678", true},
+		{"range=10000000-59999999", "50123456", true},
+		{"range=10000000-59999999", "60123456", false},
+		{"prefix=50,51", "51123456", true},
+		{"prefix=50,51", "52123456", false},
+		// Bikram Sambat years fall in the default range
+		{"not-year", "2080", false},
+		{"not-year", "1999", false},
+		{"not-year", "5012", true},
+		{"not-year", "20801", true},
+		{"not-year=2070-2090", "2060", true},
+		{"checksum=luhn", "79927398713", true},
+		{"checksum=luhn", "79927398710", false},
+		{"checksum=mod11", "123456789", true},
+		{"checksum=mod11", "123456781", false},
+	}
+	for _, tt := range tests {
+		spec, err := ParseIDRule(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseIDRule(%q): %v", tt.rule, err)
+		}
+		v, err := spec.build()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.rule, err)
+		}
+		if err := v.Validate(tt.id); (err == nil) != tt.accept {
+			t.Errorf("%s: Validate(%q) = %v, want accepted %v", tt.rule, tt.id, err, tt.accept)
+		}
+	}
+}
+
+func TestParseIDRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want ValidatorSpec
+	}{
+		{"length=8", ValidatorSpec{Rule: "length", Lengths: []int{8}}},
+		{"length=8, 10", ValidatorSpec{Rule: "length", Lengths: []int{8, 10}}},
+		{"range=1-99", ValidatorSpec{Rule: "range", Min: 1, Max: 99}},
+		{"prefix=50, 51", ValidatorSpec{Rule: "prefix", Prefixes: []string{"50", "51"}}},
+		{"not-year", ValidatorSpec{Rule: "not-year"}},
+		{"not-year=2070-2090", ValidatorSpec{Rule: "not-year", Min: 2070, Max: 2090}},
+		{"checksum=mod11", ValidatorSpec{Rule: "checksum", Algorithm: "mod11"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseIDRule(tt.rule)
+		if err != nil {
+			t.Errorf("ParseIDRule(%q): %v", tt.rule, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseIDRule(%q) = %+v, want %+v", tt.rule, got, tt.want)
+		}
+	}
+
+	for _, rule := range []string{
+		"prefix=", "prefix= ", "prefix=50,,51", "length=", "length=x", "range=5",
+		"range=9-1", "checksum=", "checksum=crc", "unknown=1",
+	} {
+		if _, err := ParseIDRule(rule); err == nil {
+			t.Errorf("ParseIDRule(%q) accepted an invalid rule", rule)
+		}
+	}
+}
+
+func TestExtractVoterIDs(t *testing.T) {
+	text := normalizeText("क.सं. १२३४५\n" +
+		"मतदाता नं: ५०१२३४५६\n" +
+		"नाम: राम बहादुर\n" +
+		"उमेर: ४५ वडा नं. ३\n" +
+		"मिति: २०८०/०१/१५\n" +
+		"12345\n" +
+		"मतदाता नं: 50123457.-\n")
+	logger := slog.New(slog.DiscardHandler)
+
+	// Ages and wards are too short for an ID, the BS year is rejected by
+	// not-year and the serial number is left out wherever it appears
+	got := extractVoterIDs(text, DefaultTemplate().ids, logger)
+	if want := []string{"50123456", "50123457"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("default rules: got %v, want %v", got, want)
+	}
+
+	tpl := DefaultTemplate()
+	if err := tpl.SetIDValidators([]ValidatorSpec{{Rule: "prefix", Prefixes: []string{"50"}}, {Rule: "length", Lengths: []int{8}}}); err != nil {
+		t.Fatal(err)
+	}
+	got = extractVoterIDs(text+"51123456 2080\n", tpl.ids, logger)
+	if want := []string{"50123456", "50123457"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("prefix and length rules: got %v, want %v", got, want)
+	}
+}
+
+func TestRejectedIDLoggedOncePerPage(t *testing.T) {
+	tpl := DefaultTemplate()
+	if err := tpl.SetIDValidators([]ValidatorSpec{{Rule: "prefix", Prefixes: []string{"50"}}}); err != nil {
+		t.Fatal(err)
+	}
+	text := normalizeText("क.सं. १\nमतदाता नं: 51123456\nमतदाताको नाम: राम बहादुर\n")
+	var log bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&log, nil))
+
+	// The ID pass, the labelled record value and the record's fallback ID
+	// pass all reject the same candidate
+	ids := tpl.ids.forPage()
+	extractVoterIDs(text, ids, logger)
+	records := parseVoterRecords(text, 2, ids, logger)
+
+	if len(records) != 1 || records[0].VoterID != "" {
+		t.Errorf("records = %+v, want one without a voter ID", records)
+	}
+	if n := strings.Count(log.String(), "Rejected ID candidate"); n != 1 {
+		t.Errorf("rejection logged %d times, want once:\n%s", n, log.String())
+	}
+}